@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/go-semantic-release/plugin-registry/internal/batch"
+	"github.com/go-semantic-release/plugin-registry/pkg/client"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var version = "dev"
+
+func main() {
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+	cmd := &cobra.Command{
+		Use:     "plugin-archive-verify",
+		Short:   "Rebuild a plugin batch archive locally and compare it with the archive served by the registry",
+		Version: version,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := run(log, cmd, args); err != nil {
+				log.Errorf("ERROR: %v", err)
+				os.Exit(1)
+			}
+		},
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+	}
+
+	cmd.PersistentFlags().StringP("registry-url", "r", client.DefaultProductionEndpoint, "the plugin registry URL")
+	cmd.PersistentFlags().String("os", runtime.GOOS, "the operating system of the plugins")
+	cmd.PersistentFlags().String("arch", runtime.GOARCH, "the architecture of the plugins")
+	cmd.PersistentFlags().StringP("format", "f", string(registry.ArchiveFormatTarGz), "the archive format")
+	cmd.PersistentFlags().StringArrayP("plugin", "p", nil, "the plugins to include in the archive (e.g. provider-github@^1.0.0)")
+	cmd.PersistentFlags().SortFlags = false
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func parsePlugins(plugins []string) []*registry.BatchRequestPlugin {
+	ret := make([]*registry.BatchRequestPlugin, len(plugins))
+	for i, p := range plugins {
+		name, constraint, _ := strings.Cut(p, "@")
+		ret[i] = &registry.BatchRequestPlugin{
+			FullName:          name,
+			VersionConstraint: constraint,
+		}
+	}
+	return ret
+}
+
+func run(log *logrus.Logger, cmd *cobra.Command, _ []string) error {
+	log.Infof("starting plugin-archive-verify (version=%s)", version)
+	plugins := must(cmd.PersistentFlags().GetStringArray("plugin"))
+	if len(plugins) == 0 {
+		return errors.New("no plugins provided")
+	}
+	batchRequest := &registry.BatchRequest{
+		OS:      must(cmd.PersistentFlags().GetString("os")),
+		Arch:    must(cmd.PersistentFlags().GetString("arch")),
+		Format:  registry.ArchiveFormat(must(cmd.PersistentFlags().GetString("format"))),
+		Plugins: parsePlugins(plugins),
+	}
+	if err := batchRequest.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	registryURL := must(cmd.PersistentFlags().GetString("registry-url"))
+	log.Infof("requesting batch archive from %s...", registryURL)
+	batchResponse, err := client.New(registryURL).SendBatchRequest(ctx, batchRequest)
+	if err != nil {
+		return err
+	}
+	if !batchResponse.VerifyHash() {
+		return fmt.Errorf("download hash mismatch: %s", batchResponse.DownloadHash)
+	}
+	for _, p := range batchResponse.Plugins {
+		log.Infof("resolved %s", p)
+	}
+
+	log.Info("rebuilding archive...")
+	archiveFileName, archiveChecksum, err := batch.DownloadFilesAndArchive(ctx, batchResponse, nil)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archiveFileName)
+
+	if archiveChecksum != batchResponse.DownloadChecksum {
+		return fmt.Errorf("archive is not reproducible: rebuilt checksum %s does not match %s", archiveChecksum, batchResponse.DownloadChecksum)
+	}
+	log.Infof("archive %s verified (checksum=%s)", batchResponse.DownloadURL, archiveChecksum)
+	return nil
+}
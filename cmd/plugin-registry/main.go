@@ -18,6 +18,7 @@ import (
 	"github.com/go-semantic-release/plugin-registry/internal/metrics"
 	"github.com/go-semantic-release/plugin-registry/internal/plugin"
 	"github.com/go-semantic-release/plugin-registry/internal/server"
+	"github.com/go-semantic-release/plugin-registry/internal/signing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -96,9 +97,21 @@ func run(log *logrus.Logger) error {
 	if err != nil {
 		return err
 	}
+
+	var signer *signing.Signer
+	if cfg.SigningPrivateKey != "" {
+		signer, err = signing.New(cfg.SigningPrivateKey)
+		if err != nil {
+			return err
+		}
+		log.Infof("signing batch responses and archives (key=%s)", signer.PublicKey().KeyID)
+	} else {
+		log.Warn("signing disabled")
+	}
+	registryServer := server.New(log, db, cfg.CreateGitHubClient(), s3Client, cfg, signer)
 	srv := &http.Server{
 		Addr:    cfg.GetServerAddr(),
-		Handler: server.New(log, db, cfg.CreateGitHubClient(), s3Client, cfg),
+		Handler: registryServer,
 	}
 	go func() {
 		log.Printf("listening on %s", srv.Addr)
@@ -128,6 +141,9 @@ func run(log *logrus.Logger) error {
 	} else if err != nil {
 		return err
 	}
+	log.Info("stopping batch job workers...")
+	registryServer.Close()
+
 	log.Info("server stopped!")
 	return nil
 }
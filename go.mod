@@ -3,9 +3,12 @@ module github.com/go-semantic-release/plugin-registry
 go 1.23
 
 require (
+	aead.dev/minisign v0.2.0
 	cloud.google.com/go/firestore v1.17.0
 	contrib.go.opencensus.io/exporter/stackdriver v0.13.14
 	github.com/Masterminds/semver/v3 v3.3.0
+	github.com/ProtonMail/go-crypto v1.0.0
+	github.com/andybalholm/brotli v1.2.0
 	github.com/aws/aws-sdk-go-v2 v1.32.2
 	github.com/aws/aws-sdk-go-v2/config v1.27.43
 	github.com/aws/aws-sdk-go-v2/credentials v1.17.41
@@ -14,6 +17,7 @@ require (
 	github.com/google/go-github/v59 v59.0.0
 	github.com/hashicorp/go-retryablehttp v0.7.7
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/klauspost/compress v1.18.0
 	github.com/migueleliasweb/go-github-mock v0.0.20
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/sirupsen/logrus v1.9.3
@@ -22,6 +26,7 @@ require (
 	go.opencensus.io v0.24.0
 	golang.org/x/oauth2 v0.23.0
 	golang.org/x/sync v0.8.0
+	google.golang.org/grpc v1.67.1
 )
 
 require (
@@ -32,7 +37,6 @@ require (
 	cloud.google.com/go/longrunning v0.6.1 // indirect
 	cloud.google.com/go/monitoring v1.21.1 // indirect
 	cloud.google.com/go/trace v1.11.1 // indirect
-	github.com/ProtonMail/go-crypto v1.0.0 // indirect
 	github.com/aws/aws-sdk-go v1.55.5 // indirect
 	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.6.6 // indirect
 	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.16.17 // indirect
@@ -82,7 +86,6 @@ require (
 	google.golang.org/genproto v0.0.0-20241007155032-5fefd90f89a9 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20241007155032-5fefd90f89a9 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241007155032-5fefd90f89a9 // indirect
-	google.golang.org/grpc v1.67.1 // indirect
 	google.golang.org/protobuf v1.35.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,104 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveModTime is the fixed modification time of all archive entries. Together with fixed ownership,
+// compression settings and ordering it makes archives byte-for-byte reproducible.
+// The zip format cannot represent dates before 1980.
+var archiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type archiveWriter interface {
+	writeFile(name string, mode, size int64, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(format registry.ArchiveFormat, w io.Writer) (archiveWriter, error) {
+	switch format {
+	case registry.ArchiveFormatTarGz:
+		gzipWriter, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		// do not leak the build environment into the gzip header
+		gzipWriter.Header = gzip.Header{OS: 255}
+		return &tarArchiveWriter{compressor: gzipWriter}, nil
+	case registry.ArchiveFormatTarZst:
+		// a single encoder goroutine produces the same output independent of the available CPUs
+		zstdWriter, err := zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.SpeedDefault),
+			zstd.WithEncoderConcurrency(1),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return &tarArchiveWriter{compressor: zstdWriter}, nil
+	case registry.ArchiveFormatZip:
+		return &zipArchiveWriter{zipWriter: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+type tarArchiveWriter struct {
+	compressor io.WriteCloser
+	tarWriter  *tar.Writer
+}
+
+func (t *tarArchiveWriter) writeFile(name string, mode, size int64, r io.Reader) error {
+	if t.tarWriter == nil {
+		t.tarWriter = tar.NewWriter(t.compressor)
+	}
+	return writeTarFile(t.tarWriter, name, mode, size, r)
+}
+
+func (t *tarArchiveWriter) Close() error {
+	if t.tarWriter == nil {
+		t.tarWriter = tar.NewWriter(t.compressor)
+	}
+	if err := t.tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := t.compressor.Close(); err != nil {
+		return fmt.Errorf("failed to close compressor: %w", err)
+	}
+	return nil
+}
+
+type zipArchiveWriter struct {
+	zipWriter *zip.Writer
+}
+
+func (z *zipArchiveWriter) writeFile(name string, mode, _ int64, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: archiveModTime,
+	}
+	header.SetMode(os.FileMode(mode))
+	fw, err := z.zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to write zip header: %w", err)
+	}
+	if _, err := io.Copy(fw, r); err != nil {
+		return fmt.Errorf("failed to write zip file: %w", err)
+	}
+	return nil
+}
+
+func (z *zipArchiveWriter) Close() error {
+	if err := z.zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return nil
+}
@@ -2,19 +2,23 @@ package batch
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -31,66 +35,293 @@ func getDefaultRetryableClient() *retryablehttp.Client {
 	return defaultRetryableClient
 }
 
-func downloadFileAndVerifyChecksum(ctx context.Context, tarWriter *tar.Writer, fileName, url, checksum string) error {
-	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// DefaultConcurrency is the default number of assets that are downloaded at the same time.
+const DefaultConcurrency = 5
+
+// BlobCache is a content-addressed store for plugin assets keyed by their SHA-256 checksum.
+type BlobCache interface {
+	Open(ctx context.Context, checksum string) (*os.File, int64, bool)
+	Put(ctx context.Context, checksum string, r io.Reader) error
+}
+
+type Options struct {
+	// Concurrency limits the number of assets that are downloaded at the same time.
+	Concurrency int
+	// BlobCache is consulted before an asset is downloaded, downloaded assets are added to it.
+	BlobCache BlobCache
+}
+
+func (o *Options) getBlobCache() BlobCache {
+	if o == nil {
+		return nil
+	}
+	return o.BlobCache
+}
+
+func (o *Options) getConcurrency() int {
+	if o == nil || o.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	return o.Concurrency
+}
+
+// downloadFileAndVerifyChecksum downloads the asset of the plugin into dst, verifies its size (if known) and checksum
+// and returns its size and SHA-256 checksum.
+func downloadFileAndVerifyChecksum(ctx context.Context, dst io.Writer, plugin *registry.BatchResponsePlugin) (int64, string, error) {
+	checksumHash := sha256.New()
+	verificationHash := checksumHash
+	writers := []io.Writer{dst, checksumHash}
+	switch algorithm := plugin.GetChecksumAlgorithm(); algorithm {
+	case registry.ChecksumAlgorithmSHA256:
+	case registry.ChecksumAlgorithmSHA512:
+		verificationHash = sha512.New()
+		writers = append(writers, verificationHash)
+	default:
+		return 0, "", fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, plugin.URL, nil)
 	if err != nil {
-		return err
+		return 0, "", err
 	}
 	resp, err := getDefaultRetryableClient().Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return 0, "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return 0, "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	expectedSize := plugin.Size
+	body := io.Reader(resp.Body)
+	if expectedSize > 0 {
+		if resp.ContentLength >= 0 && resp.ContentLength != expectedSize {
+			return 0, "", fmt.Errorf("unexpected content length: %d (should be %d)", resp.ContentLength, expectedSize)
+		}
+		// read at most one byte more than expected to detect oversized files without downloading them completely
+		body = io.LimitReader(resp.Body, expectedSize+1)
+	} else {
+		// the size of the asset is unknown, fall back to the size reported by the server
+		expectedSize = resp.ContentLength
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), body)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download file: %w", err)
+	}
+	if expectedSize >= 0 && n != expectedSize {
+		return 0, "", fmt.Errorf("unexpected file size: %d (should be %d)", n, expectedSize)
+	}
+	fileChecksum := hex.EncodeToString(checksumHash.Sum(nil))
+	if plugin.Checksum != "" && hex.EncodeToString(verificationHash.Sum(nil)) != plugin.Checksum {
+		return 0, "", fmt.Errorf("checksum verification failed")
 	}
-	err = tarWriter.WriteHeader(&tar.Header{
-		Name: fileName,
-		Mode: 0o755,
-		Size: resp.ContentLength,
+	return n, fileChecksum, nil
+}
+
+type downloadedFile struct {
+	name     string
+	file     *os.File
+	size     int64
+	checksum string
+	plugin   *registry.BatchResponsePlugin
+}
+
+func downloadToTempFile(ctx context.Context, dir, name string, plugin *registry.BatchResponsePlugin) (*downloadedFile, error) {
+	f, err := os.CreateTemp(dir, "asset-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	n, fileChecksum, err := downloadFileAndVerifyChecksum(ctx, f, plugin)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &downloadedFile{name: name, file: f, size: n, checksum: fileChecksum, plugin: plugin}, nil
+}
+
+// getFile returns the asset from the blob cache or downloads it if it is not cached yet.
+func getFile(ctx context.Context, blobCache BlobCache, dir, name string, plugin *registry.BatchResponsePlugin) (*downloadedFile, error) {
+	// assets without a SHA-256 checksum cannot be addressed by their content
+	if blobCache == nil || plugin.Checksum == "" || plugin.GetChecksumAlgorithm() != registry.ChecksumAlgorithmSHA256 {
+		return downloadToTempFile(ctx, dir, name, plugin)
+	}
+	if f, size, ok := blobCache.Open(ctx, plugin.Checksum); ok {
+		if plugin.Size <= 0 || size == plugin.Size {
+			return &downloadedFile{name: name, file: f, size: size, checksum: plugin.Checksum, plugin: plugin}, nil
+		}
+		_ = f.Close()
+	}
+	df, err := downloadToTempFile(ctx, dir, name, plugin)
+	if err != nil {
+		return nil, err
+	}
+	// caching is best effort, the downloaded file has already been verified
+	_ = blobCache.Put(ctx, plugin.Checksum, df.file)
+	if _, err := df.file.Seek(0, io.SeekStart); err != nil {
+		_ = df.file.Close()
+		return nil, err
+	}
+	return df, nil
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, mode, size int64, r io.Reader) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     size,
+		ModTime:  archiveModTime,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "root",
+		Gname:    "root",
+		Format:   tar.FormatPAX,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to write tar header: %w", err)
 	}
-	checksumReader := io.TeeReader(resp.Body, tarWriter)
-	checksumHash := sha256.New()
-	n, err := io.Copy(checksumHash, checksumReader)
-	if err != nil {
+	if _, err := io.Copy(tarWriter, r); err != nil {
 		return fmt.Errorf("failed to write tar file: %w", err)
 	}
-	if n != resp.ContentLength {
-		return fmt.Errorf("unexpected content length: %d (should be %d)", n, resp.ContentLength)
+	return nil
+}
+
+// downloadFiles downloads all plugin assets of the batch response concurrently into the given directory.
+// The returned files are in the same order as the plugins of the batch response.
+func downloadFiles(ctx context.Context, dir string, batchResponse *registry.BatchResponse, opts *Options) ([]*downloadedFile, error) {
+	files := make([]*downloadedFile, len(batchResponse.Plugins))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(opts.getConcurrency())
+	blobCache := opts.getBlobCache()
+	for i, plugin := range batchResponse.Plugins {
+		errGroup.Go(func() error {
+			fileName := fmt.Sprintf("%s_%s/%s/%s/%s", batchResponse.OS, batchResponse.Arch, plugin.FullName, plugin.Version, plugin.FileName)
+			df, err := getFile(groupCtx, blobCache, dir, fileName, plugin)
+			if err != nil {
+				return fmt.Errorf("failed to download %s: %w", plugin.FullName, err)
+			}
+			if plugin.ArchiveType != "" {
+				archivedFile := df
+				df, err = extractBinary(dir, archivedFile)
+				_ = archivedFile.file.Close()
+				if err != nil {
+					return fmt.Errorf("failed to extract %s: %w", plugin.FullName, err)
+				}
+			}
+			files[i] = df
+			return nil
+		})
 	}
-	if checksum != "" && hex.EncodeToString(checksumHash.Sum(nil)) != checksum {
-		return fmt.Errorf("checksum verification failed")
+	err := errGroup.Wait()
+	if err != nil {
+		closeFiles(files)
+		return nil, err
 	}
-	return nil
+	return files, nil
 }
 
-func DownloadFilesAndTarGz(ctx context.Context, batchResponse *registry.BatchResponse) (string, string, error) {
-	tgzFile, err := os.CreateTemp("", "plugin-archive-*.tar.gz")
+func newArchiveManifest(batchResponse *registry.BatchResponse, files []*downloadedFile) *registry.ArchiveManifest {
+	// the manifest only contains the fields that are covered by the download hash, so that all requests
+	// that share an archive produce the same archive
+	manifestResponse := &registry.BatchResponse{
+		OS:           batchResponse.OS,
+		Arch:         batchResponse.Arch,
+		Variant:      batchResponse.Variant,
+		Format:       batchResponse.GetFormat(),
+		Plugins:      make(registry.BatchResponsePlugins, len(batchResponse.Plugins)),
+		DownloadHash: batchResponse.DownloadHash,
+	}
+	for i, p := range batchResponse.Plugins {
+		manifestResponse.Plugins[i] = &registry.BatchResponsePlugin{
+			BatchRequestPlugin: &registry.BatchRequestPlugin{
+				FullName:          p.FullName,
+				VersionConstraint: p.VersionConstraint,
+			},
+			Version:           p.Version,
+			Checksum:          p.Checksum,
+			ChecksumAlgorithm: p.GetChecksumAlgorithm(),
+		}
+	}
+	sort.Sort(manifestResponse.Plugins)
+	manifest := &registry.ArchiveManifest{
+		BatchResponse: manifestResponse,
+		Files:         make([]*registry.ArchiveManifestFile, len(files)),
+	}
+	for i, df := range files {
+		manifest.Files[i] = &registry.ArchiveManifestFile{
+			Path:     df.name,
+			FullName: df.plugin.FullName,
+			Version:  df.plugin.Version,
+			Size:     df.size,
+			Checksum: df.checksum,
+		}
+	}
+	return manifest
+}
+
+func closeFiles(files []*downloadedFile) {
+	for _, df := range files {
+		if df != nil {
+			_ = df.file.Close()
+		}
+	}
+}
+
+// DownloadFilesAndArchive downloads all plugin assets of the batch response and bundles them into an archive
+// of the requested format. It returns the path of the created archive and its SHA-256 checksum.
+func DownloadFilesAndArchive(ctx context.Context, batchResponse *registry.BatchResponse, opts *Options) (string, string, error) {
+	format := batchResponse.GetFormat()
+	if !format.IsValid() {
+		return "", "", fmt.Errorf("unsupported archive format %s", format)
+	}
+
+	downloadDir, err := os.MkdirTemp("", "plugin-assets-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(downloadDir)
+
+	files, err := downloadFiles(ctx, downloadDir, batchResponse, opts)
+	if err != nil {
+		return "", "", err
+	}
+	defer closeFiles(files)
+
+	archiveFile, err := os.CreateTemp("", fmt.Sprintf("plugin-archive-*.%s", format))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create temp file: %w", err)
 	}
-	defer tgzFile.Close()
+	defer archiveFile.Close()
 
-	tgzHash := sha256.New()
-	gzipWriter := gzip.NewWriter(io.MultiWriter(tgzFile, tgzHash))
-	tarWriter := tar.NewWriter(gzipWriter)
-	for _, plugin := range batchResponse.Plugins {
-		fileName := fmt.Sprintf("%s_%s/%s/%s/%s", batchResponse.OS, batchResponse.Arch, plugin.FullName, plugin.Version, plugin.FileName)
-		err = downloadFileAndVerifyChecksum(ctx, tarWriter, fileName, plugin.URL, plugin.Checksum)
-		if err != nil {
-			return "", "", fmt.Errorf("failed to add file to tar archive: %w", err)
-		}
+	archiveHash := sha256.New()
+	aw, err := newArchiveWriter(format, io.MultiWriter(archiveFile, archiveHash))
+	if err != nil {
+		return "", "", err
 	}
-	err = tarWriter.Close()
+	// the order of the entries must not depend on the order of the plugins in the request
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	manifest, err := json.MarshalIndent(newArchiveManifest(batchResponse, files), "", "  ")
 	if err != nil {
-		return "", "", fmt.Errorf("failed to close tar writer: %w", err)
+		return "", "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	// the manifest is the first entry, so it can be read without extracting the whole archive
+	err = aw.writeFile(registry.ArchiveManifestFileName, 0o644, int64(len(manifest)), bytes.NewReader(manifest))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+	for _, df := range files {
+		err = aw.writeFile(df.name, 0o755, df.size, df.file)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to add file to archive: %w", err)
+		}
 	}
-	err = gzipWriter.Close()
+	err = aw.Close()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to close gzip writer: %w", err)
+		return "", "", err
 	}
-	return tgzFile.Name(), hex.EncodeToString(tgzHash.Sum(nil)), nil
+	return archiveFile.Name(), hex.EncodeToString(archiveHash.Sum(nil)), nil
 }
@@ -2,19 +2,26 @@ package batch
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/go-semantic-release/plugin-registry/internal/blobcache"
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,10 +31,9 @@ var (
 )
 
 func getTestServer(t *testing.T, failingRequests int) *httptest.Server {
-	cnt := 0
+	var cnt atomic.Int32
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		cnt++
-		if cnt <= failingRequests {
+		if int(cnt.Add(1)) <= failingRequests {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -36,36 +42,106 @@ func getTestServer(t *testing.T, failingRequests int) *httptest.Server {
 	}))
 }
 
+func newTestPlugin(url, checksum string, algorithm registry.ChecksumAlgorithm, size int64) *registry.BatchResponsePlugin {
+	return &registry.BatchResponsePlugin{
+		BatchRequestPlugin: &registry.BatchRequestPlugin{FullName: "provider-test"},
+		Version:            "1.0.0",
+		FileName:           "test",
+		URL:                url,
+		Checksum:           checksum,
+		ChecksumAlgorithm:  algorithm,
+		Size:               size,
+	}
+}
+
 func TestDownloadFileAndVerifyChecksum(t *testing.T) {
 	ts := getTestServer(t, 0)
 	defer ts.Close()
 
-	var tarBuffer bytes.Buffer
-	tarWriter := tar.NewWriter(&tarBuffer)
+	var fileBuffer bytes.Buffer
+	n, checksum, err := downloadFileAndVerifyChecksum(context.Background(), &fileBuffer, newTestPlugin(ts.URL, testFileChecksum, "", 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(testFile)), n)
+	require.Equal(t, testFileChecksum, checksum)
+	require.Equal(t, testFile, fileBuffer.Bytes())
+}
 
-	err := downloadFileAndVerifyChecksum(context.Background(), tarWriter, "test", ts.URL, testFileChecksum)
+func TestDownloadFileAndVerifyChecksumRetry(t *testing.T) {
+	ts := getTestServer(t, 1)
+	defer ts.Close()
+
+	var fileBuffer bytes.Buffer
+	n, checksum, err := downloadFileAndVerifyChecksum(context.Background(), &fileBuffer, newTestPlugin(ts.URL, testFileChecksum, "", 0))
 	require.NoError(t, err)
-	require.NoError(t, tarWriter.Close())
+	require.Equal(t, int64(len(testFile)), n)
+	require.Equal(t, testFileChecksum, checksum)
+	require.Equal(t, testFile, fileBuffer.Bytes())
+}
 
-	tarReader := tar.NewReader(&tarBuffer)
-	tarHeader, err := tarReader.Next()
+func TestDownloadFileAndVerifyChecksumMismatch(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	_, _, err := downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, "invalid", "", 0))
+	require.ErrorContains(t, err, "checksum verification failed")
+}
+
+func TestDownloadFileAndVerifySHA512Checksum(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	sha512Checksum := sha512.Sum512(testFile)
+	n, checksum, err := downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, hex.EncodeToString(sha512Checksum[:]), registry.ChecksumAlgorithmSHA512, 0))
 	require.NoError(t, err)
-	require.Equal(t, "test", tarHeader.Name)
-	require.Equal(t, int64(len(testFile)), tarHeader.Size)
-	fileContent, err := io.ReadAll(tarReader)
+	require.Equal(t, int64(len(testFile)), n)
+	// the SHA-256 checksum is always returned
+	require.Equal(t, testFileChecksum, checksum)
+
+	_, _, err = downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, testFileChecksum, registry.ChecksumAlgorithmSHA512, 0))
+	require.ErrorContains(t, err, "checksum verification failed")
+}
+
+func TestDownloadFileAndVerifySize(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	n, _, err := downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, testFileChecksum, "", int64(len(testFile))))
 	require.NoError(t, err)
-	require.Equal(t, testFile, fileContent)
+	require.Equal(t, int64(len(testFile)), n)
+
+	_, _, err = downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, testFileChecksum, "", int64(len(testFile))+1))
+	require.ErrorContains(t, err, "unexpected content length")
 }
 
-func TestDownloadFileAndVerifyChecksumRetry(t *testing.T) {
-	ts := getTestServer(t, 1)
+func TestDownloadFileAndVerifySizeWithoutContentLength(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// flushing before writing the body forces a chunked response without a content length
+		w.(http.Flusher).Flush()
+		_, err := w.Write(testFile)
+		require.NoError(t, err)
+	}))
 	defer ts.Close()
 
-	var tarBuffer bytes.Buffer
-	tarWriter := tar.NewWriter(&tarBuffer)
+	_, _, err := downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, "", "", int64(len(testFile))-1))
+	require.ErrorContains(t, err, "unexpected file size")
 
-	err := downloadFileAndVerifyChecksum(context.Background(), tarWriter, "test", ts.URL, testFileChecksum)
+	n, checksum, err := downloadFileAndVerifyChecksum(context.Background(), io.Discard, newTestPlugin(ts.URL, testFileChecksum, "", int64(len(testFile))))
 	require.NoError(t, err)
+	require.Equal(t, int64(len(testFile)), n)
+	require.Equal(t, testFileChecksum, checksum)
+}
+
+func TestWriteTarFile(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	df, err := downloadToTempFile(context.Background(), t.TempDir(), "test", newTestPlugin(ts.URL, testFileChecksum, "", 0))
+	require.NoError(t, err)
+	defer df.file.Close()
+
+	var tarBuffer bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuffer)
+	require.NoError(t, writeTarFile(tarWriter, df.name, 0o755, df.size, df.file))
 	require.NoError(t, tarWriter.Close())
 
 	tarReader := tar.NewReader(&tarBuffer)
@@ -90,7 +166,7 @@ func createBatchResponsePlugin(url string, i int) *registry.BatchResponsePlugin
 	}
 }
 
-func TestDownloadFilesAndTarGz(t *testing.T) {
+func TestDownloadFilesAndArchive(t *testing.T) {
 	ts := getTestServer(t, 0)
 	defer ts.Close()
 
@@ -104,7 +180,7 @@ func TestDownloadFilesAndTarGz(t *testing.T) {
 		Plugins: plugins,
 	}
 
-	tgzFileName, tgzChecksum, err := DownloadFilesAndTarGz(context.Background(), batchResponse)
+	tgzFileName, tgzChecksum, err := DownloadFilesAndArchive(context.Background(), batchResponse, nil)
 	require.NoError(t, err)
 	require.NotEmpty(t, tgzFileName)
 	defer os.Remove(tgzFileName)
@@ -121,6 +197,9 @@ func TestDownloadFilesAndTarGz(t *testing.T) {
 	defer gzipReader.Close()
 
 	tarReader := tar.NewReader(gzipReader)
+	manifestHeader, err := tarReader.Next()
+	require.NoError(t, err)
+	require.Equal(t, registry.ArchiveManifestFileName, manifestHeader.Name)
 	for i := 0; i < 10; i++ {
 		tarHeader, err := tarReader.Next()
 		require.NoError(t, err)
@@ -132,3 +211,257 @@ func TestDownloadFilesAndTarGz(t *testing.T) {
 		require.Equal(t, testFile, fileContent)
 	}
 }
+
+func TestDownloadFilesAndArchiveConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if current <= m || maxInFlight.CompareAndSwap(m, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write(testFile)
+	}))
+	defer ts.Close()
+
+	plugins := make([]*registry.BatchResponsePlugin, 0)
+	for i := 0; i < 10; i++ {
+		plugins = append(plugins, createBatchResponsePlugin(ts.URL, i))
+	}
+	batchResponse := &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: plugins,
+	}
+
+	tgzFileName, _, err := DownloadFilesAndArchive(context.Background(), batchResponse, &Options{Concurrency: 3})
+	require.NoError(t, err)
+	defer os.Remove(tgzFileName)
+	require.Greater(t, maxInFlight.Load(), int32(1))
+	require.LessOrEqual(t, maxInFlight.Load(), int32(3))
+}
+
+func TestDownloadFilesAndArchiveFailure(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	plugin := createBatchResponsePlugin(ts.URL, 0)
+	plugin.Checksum = "invalid"
+	batchResponse := &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchResponsePlugin{createBatchResponsePlugin(ts.URL, 1), plugin},
+	}
+	_, _, err := DownloadFilesAndArchive(context.Background(), batchResponse, nil)
+	require.ErrorContains(t, err, "failed to download test-0")
+}
+
+func TestDownloadFilesAndArchiveBlobCache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(testFile)
+	}))
+	defer ts.Close()
+
+	blobCache, err := blobcache.New(t.TempDir(), 1024)
+	require.NoError(t, err)
+	batchResponse := &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchResponsePlugin{createBatchResponsePlugin(ts.URL, 0)},
+	}
+	opts := &Options{BlobCache: blobCache}
+
+	tgzFileName, tgzChecksum, err := DownloadFilesAndArchive(context.Background(), batchResponse, opts)
+	require.NoError(t, err)
+	defer os.Remove(tgzFileName)
+	require.Equal(t, int32(1), requests.Load())
+	require.Equal(t, int64(len(testFile)), blobCache.Size())
+
+	// the second archive is built from the blob cache
+	batchResponse.Plugins = append(batchResponse.Plugins, createBatchResponsePlugin(ts.URL, 1))
+	cachedTgzFileName, cachedTgzChecksum, err := DownloadFilesAndArchive(context.Background(), batchResponse, opts)
+	require.NoError(t, err)
+	defer os.Remove(cachedTgzFileName)
+	require.Equal(t, int32(1), requests.Load())
+	require.NotEqual(t, tgzChecksum, cachedTgzChecksum)
+}
+
+func createTestBatchResponse(url string, format registry.ArchiveFormat) *registry.BatchResponse {
+	plugins := make([]*registry.BatchResponsePlugin, 0)
+	for i := 0; i < 3; i++ {
+		plugins = append(plugins, createBatchResponsePlugin(url, i))
+	}
+	return &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Format:  format,
+		Plugins: plugins,
+	}
+}
+
+func TestDownloadFilesAndArchiveZip(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	zipFileName, _, err := DownloadFilesAndArchive(context.Background(), createTestBatchResponse(ts.URL, registry.ArchiveFormatZip), nil)
+	require.NoError(t, err)
+	defer os.Remove(zipFileName)
+	require.True(t, strings.HasSuffix(zipFileName, ".zip"))
+
+	zipReader, err := zip.OpenReader(zipFileName)
+	require.NoError(t, err)
+	defer zipReader.Close()
+	require.Len(t, zipReader.File, 4)
+	require.Equal(t, registry.ArchiveManifestFileName, zipReader.File[0].Name)
+	for i, zf := range zipReader.File[1:] {
+		require.Equal(t, fmt.Sprintf("linux_amd64/test-%d/1.0.0/test", i), zf.Name)
+		require.Equal(t, os.FileMode(0o755), zf.Mode().Perm())
+		rc, err := zf.Open()
+		require.NoError(t, err)
+		fileContent, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		require.Equal(t, testFile, fileContent)
+	}
+}
+
+func TestDownloadFilesAndArchiveTarZst(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	zstFileName, _, err := DownloadFilesAndArchive(context.Background(), createTestBatchResponse(ts.URL, registry.ArchiveFormatTarZst), nil)
+	require.NoError(t, err)
+	defer os.Remove(zstFileName)
+	require.True(t, strings.HasSuffix(zstFileName, ".tar.zst"))
+
+	zstFile, err := os.Open(zstFileName)
+	require.NoError(t, err)
+	defer zstFile.Close()
+	zstdReader, err := zstd.NewReader(zstFile)
+	require.NoError(t, err)
+	defer zstdReader.Close()
+
+	tarReader := tar.NewReader(zstdReader)
+	_, err = tarReader.Next()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		tarHeader, err := tarReader.Next()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("linux_amd64/test-%d/1.0.0/test", i), tarHeader.Name)
+		fileContent, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		require.Equal(t, testFile, fileContent)
+	}
+	_, err = tarReader.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestDownloadFilesAndArchiveUnsupportedFormat(t *testing.T) {
+	_, _, err := DownloadFilesAndArchive(context.Background(), createTestBatchResponse("http://localhost", "rar"), nil)
+	require.ErrorContains(t, err, "unsupported archive format")
+}
+
+func TestDownloadFilesAndArchiveIsReproducible(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	for _, format := range registry.ArchiveFormats {
+		t.Run(string(format), func(t *testing.T) {
+			batchResponse := createTestBatchResponse(ts.URL, format)
+			fileName, checksum, err := DownloadFilesAndArchive(context.Background(), batchResponse, nil)
+			require.NoError(t, err)
+			defer os.Remove(fileName)
+
+			// the order of the plugins must not affect the archive
+			plugins := batchResponse.Plugins
+			plugins[0], plugins[2] = plugins[2], plugins[0]
+			otherFileName, otherChecksum, err := DownloadFilesAndArchive(context.Background(), batchResponse, &Options{Concurrency: 1})
+			require.NoError(t, err)
+			defer os.Remove(otherFileName)
+			require.Equal(t, checksum, otherChecksum)
+		})
+	}
+}
+
+func TestTarHeadersAreNormalized(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	tgzFileName, _, err := DownloadFilesAndArchive(context.Background(), createTestBatchResponse(ts.URL, registry.ArchiveFormatTarGz), nil)
+	require.NoError(t, err)
+	defer os.Remove(tgzFileName)
+
+	tgzFile, err := os.Open(tgzFileName)
+	require.NoError(t, err)
+	defer tgzFile.Close()
+	gzipReader, err := gzip.NewReader(tgzFile)
+	require.NoError(t, err)
+	require.True(t, gzipReader.ModTime.IsZero())
+	require.Empty(t, gzipReader.Name)
+
+	tarReader := tar.NewReader(gzipReader)
+	_, err = tarReader.Next()
+	require.NoError(t, err)
+	tarHeader, err := tarReader.Next()
+	require.NoError(t, err)
+	require.True(t, archiveModTime.Equal(tarHeader.ModTime))
+	require.Equal(t, 0, tarHeader.Uid)
+	require.Equal(t, 0, tarHeader.Gid)
+	require.Equal(t, "root", tarHeader.Uname)
+	require.Equal(t, "root", tarHeader.Gname)
+}
+
+func TestDownloadFilesAndArchiveManifest(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	for _, format := range registry.ArchiveFormats {
+		t.Run(string(format), func(t *testing.T) {
+			batchResponse := createTestBatchResponse(ts.URL, format)
+			// the checksum of the second plugin is unknown and computed while downloading
+			batchResponse.Plugins[1].Checksum = ""
+			batchResponse.CalculateHash()
+			batchResponse.DownloadChecksum = "should-not-be-embedded"
+			fileName, _, err := DownloadFilesAndArchive(context.Background(), batchResponse, nil)
+			require.NoError(t, err)
+			defer os.Remove(fileName)
+
+			manifest, err := registry.VerifyArchive(fileName, format)
+			require.NoError(t, err)
+			require.Equal(t, batchResponse.DownloadHash, manifest.DownloadHash)
+			require.Empty(t, manifest.DownloadChecksum)
+			require.Len(t, manifest.Files, 3)
+			for i, mf := range manifest.Files {
+				require.Equal(t, fmt.Sprintf("linux_amd64/test-%d/1.0.0/test", i), mf.Path)
+				require.Equal(t, fmt.Sprintf("test-%d", i), mf.FullName)
+				require.Equal(t, "1.0.0", mf.Version)
+				require.Equal(t, int64(len(testFile)), mf.Size)
+				require.Equal(t, testFileChecksum, mf.Checksum)
+			}
+		})
+	}
+}
+
+func TestArchiveManifestOnlyContainsHashedFields(t *testing.T) {
+	batchResponse := createTestBatchResponse("http://localhost", registry.ArchiveFormatTarGz)
+	batchResponse.CalculateHash()
+	otherResponse := createTestBatchResponse("http://localhost", registry.ArchiveFormatTarGz)
+	otherResponse.SemanticReleaseVersion = "2.0.0"
+	otherResponse.AutoComplete = true
+	otherResponse.DownloadURL = "https://example.com/archive.tar.gz"
+	otherResponse.Plugins[0].RequiredBy = "test-1"
+	otherResponse.CalculateHash()
+	require.Equal(t, batchResponse.DownloadHash, otherResponse.DownloadHash)
+
+	manifest := newArchiveManifest(batchResponse, nil)
+	require.Equal(t, manifest, newArchiveManifest(otherResponse, nil))
+	require.True(t, manifest.VerifyHash())
+	require.Empty(t, manifest.SemanticReleaseVersion)
+	require.Empty(t, manifest.DownloadURL)
+}
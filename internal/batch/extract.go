@@ -0,0 +1,174 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+// maxExtractedBinarySize limits the size of a plugin binary that is extracted from an archived asset.
+const maxExtractedBinarySize = 512 << 20
+
+// nonBinaryFileRe matches files that are commonly shipped next to the binary in archived assets.
+var nonBinaryFileRe = regexp.MustCompile(`(?i)^(readme|license|licence|changelog|notice|copying)([._-].*)?$|\.(md|txt|json|ya?ml|sig|pem|asc|sbom)$`)
+
+type assetArchiveEntry struct {
+	name       string
+	executable bool
+}
+
+// walkAssetArchive calls fn for every regular file of the archived asset.
+func walkAssetArchive(f *os.File, size int64, archiveType registry.ArchiveType, fn func(entry *assetArchiveEntry, r io.Reader) error) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch archiveType {
+	case registry.ArchiveTypeTarGz:
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gzipReader.Close()
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			hdr, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read tar archive: %w", err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := fn(&assetArchiveEntry{name: hdr.Name, executable: hdr.Mode&0o111 != 0}, tarReader); err != nil {
+				return err
+			}
+		}
+	case registry.ArchiveTypeZip:
+		zipReader, err := zip.NewReader(f, size)
+		if err != nil {
+			return fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		for _, zf := range zipReader.File {
+			if !zf.Mode().IsRegular() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", zf.Name, err)
+			}
+			err = fn(&assetArchiveEntry{name: zf.Name, executable: zf.Mode()&0o111 != 0}, rc)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported asset archive type %s", archiveType)
+	}
+}
+
+// selectBinary returns the entry that contains the plugin binary. An entry named like the archive itself wins,
+// otherwise the binary must be the only file (or the only executable file) that is not documentation.
+func selectBinary(entries []*assetArchiveEntry, binaryName string) (*assetArchiveEntry, error) {
+	candidates := make([]*assetArchiveEntry, 0, len(entries))
+	executables := make([]*assetArchiveEntry, 0, len(entries))
+	for _, entry := range entries {
+		baseName := path.Base(entry.name)
+		if nonBinaryFileRe.MatchString(baseName) {
+			continue
+		}
+		if strings.TrimSuffix(baseName, ".exe") == strings.TrimSuffix(binaryName, ".exe") {
+			return entry, nil
+		}
+		candidates = append(candidates, entry)
+		if entry.executable || strings.HasSuffix(strings.ToLower(baseName), ".exe") {
+			executables = append(executables, entry)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(executables) == 1:
+		return executables[0], nil
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("archive does not contain a plugin binary")
+	default:
+		return nil, fmt.Errorf("archive contains %d possible plugin binaries", len(candidates))
+	}
+}
+
+// extractBinary replaces the downloaded archived asset with the plugin binary it contains.
+// The binary is named like the archive without its extension, so it has the same name as a raw binary asset.
+func extractBinary(dir string, df *downloadedFile) (*downloadedFile, error) {
+	archiveType := df.plugin.ArchiveType
+	_, binaryName := registry.ArchiveTypeFromFileName(path.Base(df.name))
+	entries := make([]*assetArchiveEntry, 0)
+	err := walkAssetArchive(df.file, df.size, archiveType, func(entry *assetArchiveEntry, _ io.Reader) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	binary, err := selectBinary(entries, binaryName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(strings.ToLower(binary.name), ".exe") && !strings.HasSuffix(strings.ToLower(binaryName), ".exe") {
+		binaryName += ".exe"
+	}
+
+	f, err := os.CreateTemp(dir, "binary-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	h := sha256.New()
+	var n int64
+	errFound := errors.New("binary found")
+	err = walkAssetArchive(df.file, df.size, archiveType, func(entry *assetArchiveEntry, r io.Reader) error {
+		if entry.name != binary.name {
+			return nil
+		}
+		var copyErr error
+		n, copyErr = io.Copy(io.MultiWriter(f, h), io.LimitReader(r, maxExtractedBinarySize+1))
+		if copyErr != nil {
+			return fmt.Errorf("failed to extract %s: %w", entry.name, copyErr)
+		}
+		return errFound
+	})
+	if errors.Is(err, errFound) {
+		err = nil
+		if n > maxExtractedBinarySize {
+			err = fmt.Errorf("plugin binary %s exceeds the maximum size of %d bytes", binary.name, maxExtractedBinarySize)
+		}
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return &downloadedFile{
+		name:     path.Join(path.Dir(df.name), binaryName),
+		file:     f,
+		size:     n,
+		checksum: hex.EncodeToString(h.Sum(nil)),
+		plugin:   df.plugin,
+	}, nil
+}
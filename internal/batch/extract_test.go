@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+type testArchiveFile struct {
+	name string
+	mode int64
+	data []byte
+}
+
+func createTestTarGz(t *testing.T, files ...testArchiveFile) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dist/", Mode: 0o755}))
+	for _, f := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: f.mode, Size: int64(len(f.data))}))
+		_, err := tarWriter.Write(f.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func createTestZip(t *testing.T, files ...testArchiveFile) []byte {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zipWriter.Create(f.name)
+		require.NoError(t, err)
+		_, err = w.Write(f.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func TestSelectBinary(t *testing.T) {
+	testCases := []struct {
+		entries  []*assetArchiveEntry
+		expected string
+	}{
+		{[]*assetArchiveEntry{{name: "README.md"}, {name: "LICENSE"}, {name: "provider-git"}}, "provider-git"},
+		{[]*assetArchiveEntry{{name: "dist/provider-git_v1.0.0_linux_amd64"}, {name: "dist/other"}}, "dist/provider-git_v1.0.0_linux_amd64"},
+		{[]*assetArchiveEntry{{name: "provider-git", executable: true}, {name: "completions.sh"}}, "provider-git"},
+		{[]*assetArchiveEntry{{name: "provider-git.exe"}, {name: "provider-git.bat"}}, "provider-git.exe"},
+		{[]*assetArchiveEntry{{name: "a", executable: true}, {name: "b", executable: true}}, ""},
+		{[]*assetArchiveEntry{{name: "README.md"}}, ""},
+	}
+	for _, tc := range testCases {
+		entry, err := selectBinary(tc.entries, "provider-git_v1.0.0_linux_amd64")
+		if tc.expected == "" {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, entry.name)
+	}
+}
+
+func TestDownloadFilesAndArchiveExtractsArchivedAssets(t *testing.T) {
+	files := map[string][]byte{
+		"/plugin_linux_amd64.tar.gz": createTestTarGz(t,
+			testArchiveFile{name: "dist/README.md", mode: 0o644, data: []byte("readme")},
+			testArchiveFile{name: "dist/plugin", mode: 0o755, data: testFile},
+		),
+		"/plugin_windows_amd64.zip": createTestZip(t,
+			testArchiveFile{name: "LICENSE", data: []byte("license")},
+			testArchiveFile{name: "plugin.exe", data: testFile},
+		),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(files[r.URL.Path])
+	}))
+	defer ts.Close()
+
+	tgzPlugin := createBatchResponsePlugin(ts.URL+"/plugin_linux_amd64.tar.gz", 0)
+	tgzPlugin.FileName = "plugin_linux_amd64.tar.gz"
+	tgzPlugin.Checksum = ""
+	tgzPlugin.ArchiveType = registry.ArchiveTypeTarGz
+	zipPlugin := createBatchResponsePlugin(ts.URL+"/plugin_windows_amd64.zip", 1)
+	zipPlugin.FileName = "plugin_windows_amd64.zip"
+	zipPlugin.Checksum = ""
+	zipPlugin.ArchiveType = registry.ArchiveTypeZip
+	batchResponse := &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchResponsePlugin{tgzPlugin, zipPlugin},
+	}
+	batchResponse.CalculateHash()
+
+	fileName, _, err := DownloadFilesAndArchive(context.Background(), batchResponse, nil)
+	require.NoError(t, err)
+	defer os.Remove(fileName)
+
+	manifest, err := registry.VerifyArchive(fileName, registry.ArchiveFormatTarGz)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	require.Equal(t, "linux_amd64/test-0/1.0.0/plugin_linux_amd64", manifest.Files[0].Path)
+	require.Equal(t, "linux_amd64/test-1/1.0.0/plugin_windows_amd64.exe", manifest.Files[1].Path)
+	for _, mf := range manifest.Files {
+		require.Equal(t, int64(len(testFile)), mf.Size)
+		require.Equal(t, testFileChecksum, mf.Checksum)
+	}
+}
+
+func TestDownloadFilesAndArchiveInvalidArchivedAsset(t *testing.T) {
+	ts := getTestServer(t, 0)
+	defer ts.Close()
+
+	plugin := createBatchResponsePlugin(ts.URL, 0)
+	plugin.ArchiveType = registry.ArchiveTypeZip
+	_, _, err := DownloadFilesAndArchive(context.Background(), &registry.BatchResponse{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchResponsePlugin{plugin},
+	}, nil)
+	require.ErrorContains(t, err, "failed to extract test-0")
+}
+
+func TestExtractBinaryRemovesTempFileOnError(t *testing.T) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "plugin_linux_amd64", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("binary content"))
+	require.NoError(t, err)
+	require.NoError(t, zipWriter.Close())
+	// the entries can be listed, but the extraction fails with a checksum error
+	data := buf.Bytes()
+	data[bytes.Index(data, []byte("binary content"))] = 'B'
+
+	f, err := os.CreateTemp(t.TempDir(), "asset-*")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(data)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	_, err = extractBinary(dir, &downloadedFile{
+		name:   "linux_amd64/test/1.0.0/plugin_linux_amd64.zip",
+		file:   f,
+		size:   int64(len(data)),
+		plugin: &registry.BatchResponsePlugin{ArchiveType: registry.ArchiveTypeZip},
+	})
+	require.ErrorContains(t, err, "checksum error")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
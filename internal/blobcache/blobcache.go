@@ -0,0 +1,215 @@
+package blobcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-semantic-release/plugin-registry/internal/metrics"
+	"go.opencensus.io/stats"
+)
+
+// tmpFilePrefix is the prefix of the files that are written by Put before they are renamed to their checksum.
+const tmpFilePrefix = "tmp-"
+
+type entry struct {
+	checksum string
+	size     int64
+}
+
+// Cache is a content-addressed cache for plugin binaries on the local filesystem. Blobs are stored by
+// their SHA-256 checksum and the least recently used blobs are evicted once the cache exceeds its maximum size.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+func isValidChecksum(checksum string) bool {
+	if len(checksum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(checksum)
+	return err == nil
+}
+
+// New creates a cache in the given directory and indexes all blobs that already exist in it.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid blob cache size: %d", maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob cache directory: %w", err)
+	}
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read blob cache directory: %w", err)
+	}
+	files := make([]os.FileInfo, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(de.Name(), tmpFilePrefix) {
+			// remove leftovers of interrupted writes
+			_ = os.Remove(filepath.Join(c.dir, de.Name()))
+			continue
+		}
+		// the directory may be shared with other files, which are neither loaded nor removed
+		if !isValidChecksum(de.Name()) {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat blob %s: %w", de.Name(), err)
+		}
+		files = append(files, fi)
+	}
+	// the most recently modified blobs are the most recently used ones
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, fi := range files {
+		c.entries[fi.Name()] = c.lru.PushFront(&entry{checksum: fi.Name(), size: fi.Size()})
+		c.size += fi.Size()
+	}
+	c.evict(context.Background())
+	return nil
+}
+
+func (c *Cache) path(checksum string) string {
+	return filepath.Join(c.dir, checksum)
+}
+
+// Size returns the total size of all cached blobs.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Open returns the cached blob with the given checksum and its size.
+func (c *Cache) Open(ctx context.Context, checksum string) (*os.File, int64, bool) {
+	c.mu.Lock()
+	el, ok := c.entries[checksum]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		stats.Record(ctx, metrics.CounterBlobCacheMiss.M(1))
+		return nil, 0, false
+	}
+
+	// the blob is opened after releasing the lock, an evicted blob can still be read through an open file
+	f, err := os.Open(c.path(checksum))
+	if err != nil {
+		c.remove(checksum)
+		stats.Record(ctx, metrics.CounterBlobCacheMiss.M(1))
+		return nil, 0, false
+	}
+	stats.Record(ctx, metrics.CounterBlobCacheHit.M(1))
+	return f, el.Value.(*entry).size, true
+}
+
+// Put stores the content of the reader in the cache. The content must match the given SHA-256 checksum.
+func (c *Cache) Put(ctx context.Context, checksum string, r io.Reader) error {
+	if !isValidChecksum(checksum) {
+		return fmt.Errorf("invalid checksum: %s", checksum)
+	}
+	c.mu.Lock()
+	_, exists := c.entries[checksum]
+	c.mu.Unlock()
+	if exists {
+		return nil
+	}
+
+	tmpFile, err := os.CreateTemp(c.dir, tmpFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, h), r)
+	closeErr := tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close blob: %w", closeErr)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != checksum {
+		return fmt.Errorf("checksum verification failed")
+	}
+	if err := os.Rename(tmpFile.Name(), c.path(checksum)); err != nil {
+		return fmt.Errorf("failed to move blob: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[checksum]; exists {
+		// the blob has been added concurrently
+		return nil
+	}
+	c.entries[checksum] = c.lru.PushFront(&entry{checksum: checksum, size: size})
+	c.size += size
+	c.evict(ctx)
+	return nil
+}
+
+func (c *Cache) remove(checksum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[checksum]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, checksum)
+		c.size -= el.Value.(*entry).size
+	}
+}
+
+// evict removes the least recently used blobs until the cache fits into its maximum size.
+// It must be called with the lock held.
+func (c *Cache) evict(ctx context.Context) {
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil {
+			break
+		}
+		e := el.Value.(*entry)
+		c.lru.Remove(el)
+		delete(c.entries, e.checksum)
+		c.size -= e.size
+		_ = os.Remove(c.path(e.checksum))
+		stats.Record(ctx, metrics.CounterBlobCacheEvict.M(1))
+	}
+	stats.Record(ctx, metrics.GaugeBlobCacheSize.M(c.size))
+}
@@ -0,0 +1,104 @@
+package blobcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func checksumOf(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func readBlob(t *testing.T, c *Cache, checksum string) []byte {
+	f, size, ok := c.Open(context.Background(), checksum)
+	require.True(t, ok)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), size)
+	return data
+}
+
+func TestPutAndOpen(t *testing.T) {
+	c, err := New(t.TempDir(), 1024)
+	require.NoError(t, err)
+
+	blob := []byte("test-file")
+	checksum := checksumOf(blob)
+	_, _, ok := c.Open(context.Background(), checksum)
+	require.False(t, ok)
+
+	require.NoError(t, c.Put(context.Background(), checksum, bytes.NewReader(blob)))
+	require.Equal(t, blob, readBlob(t, c, checksum))
+	require.Equal(t, int64(len(blob)), c.Size())
+
+	// adding the same blob twice is a no-op
+	require.NoError(t, c.Put(context.Background(), checksum, bytes.NewReader(blob)))
+	require.Equal(t, int64(len(blob)), c.Size())
+}
+
+func TestPutChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1024)
+	require.NoError(t, err)
+
+	err = c.Put(context.Background(), checksumOf([]byte("other")), bytes.NewReader([]byte("test-file")))
+	require.ErrorContains(t, err, "checksum verification failed")
+	require.ErrorContains(t, c.Put(context.Background(), "../../etc/passwd", bytes.NewReader(nil)), "invalid checksum")
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestLRUEviction(t *testing.T) {
+	c, err := New(t.TempDir(), 25)
+	require.NoError(t, err)
+
+	blobs := [][]byte{[]byte("blob-1-...."), []byte("blob-2-...."), []byte("blob-3-....")}
+	require.NoError(t, c.Put(context.Background(), checksumOf(blobs[0]), bytes.NewReader(blobs[0])))
+	require.NoError(t, c.Put(context.Background(), checksumOf(blobs[1]), bytes.NewReader(blobs[1])))
+	// blob 1 has been used more recently than blob 2
+	readBlob(t, c, checksumOf(blobs[0]))
+	require.NoError(t, c.Put(context.Background(), checksumOf(blobs[2]), bytes.NewReader(blobs[2])))
+
+	_, _, ok := c.Open(context.Background(), checksumOf(blobs[1]))
+	require.False(t, ok)
+	require.Equal(t, blobs[0], readBlob(t, c, checksumOf(blobs[0])))
+	require.Equal(t, blobs[2], readBlob(t, c, checksumOf(blobs[2])))
+	require.Equal(t, int64(22), c.Size())
+	_, err = os.Stat(filepath.Join(c.dir, checksumOf(blobs[1])))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadExistingBlobs(t *testing.T) {
+	dir := t.TempDir()
+	oldBlob, newBlob := []byte("old-blob"), []byte("new-blob")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, checksumOf(oldBlob)), oldBlob, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, checksumOf(newBlob)), newBlob, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tmp-123"), []byte("partial"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0o644))
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, checksumOf(oldBlob)), past, past))
+
+	c, err := New(dir, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(newBlob)), c.Size())
+	require.Equal(t, newBlob, readBlob(t, c, checksumOf(newBlob)))
+	_, _, ok := c.Open(context.Background(), checksumOf(oldBlob))
+	require.False(t, ok)
+	_, err = os.Stat(filepath.Join(dir, "tmp-123"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dir, "other.txt"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,14 @@
+package config
+
+import "github.com/go-semantic-release/plugin-registry/internal/plugin"
+
+// PlatformFallbacks lists the platforms that are served if a plugin release has no asset for the requested platform.
+// arm platforms additionally fall back to lower GOARM variants.
+var PlatformFallbacks = plugin.PlatformFallbacks{
+	// universal binaries run natively, amd64 binaries run with Rosetta 2 on Apple silicon
+	"darwin/arm64": {"darwin/all", "darwin/amd64"},
+	"darwin/amd64": {"darwin/all"},
+	// 64-bit Windows runs 32-bit binaries, Windows on arm emulates amd64 and 386
+	"windows/amd64": {"windows/386"},
+	"windows/arm64": {"windows/amd64", "windows/386"},
+}
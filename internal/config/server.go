@@ -27,6 +27,13 @@ type ServerConfig struct {
 	CloudflareAccountID         string `envconfig:"CLOUDFLARE_ACCOUNT_ID" required:"true"`
 	PluginCacheHost             string `envconfig:"PLUGIN_CACHE_HOST" required:"true"`
 	DisableRequestCache         bool   `envconfig:"DISABLE_REQUEST_CACHE"`
+	BatchArchiveConcurrency     int    `envconfig:"BATCH_ARCHIVE_CONCURRENCY" default:"4"`
+	BatchDownloadConcurrency    int    `envconfig:"BATCH_DOWNLOAD_CONCURRENCY" default:"5"`
+	BlobCacheDir                string `envconfig:"BLOB_CACHE_DIR"`
+	BlobCacheMaxSize            int64  `envconfig:"BLOB_CACHE_MAX_SIZE" default:"1073741824"`
+	BatchJobWorkers             int    `envconfig:"BATCH_JOB_WORKERS" default:"2"`
+	BatchJobQueueSize           int    `envconfig:"BATCH_JOB_QUEUE_SIZE" default:"100"`
+	SigningPrivateKey           string `envconfig:"SIGNING_PRIVATE_KEY"`
 	Version                     string
 	DisableMetrics              bool `envconfig:"DISABLE_METRICS"`
 }
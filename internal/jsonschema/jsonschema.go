@@ -0,0 +1,130 @@
+package jsonschema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Naming selects how the properties of the generated definitions are named.
+type Naming int
+
+const (
+	// JSONTags names the properties after the json tags. Fields without tag are skipped and omitempty fields are optional.
+	JSONTags Naming = iota
+	// FieldNames names the properties after the Go field names like encoding/json does for types without tags.
+	// Embedded structs are flattened and pointers, slices and maps are nullable.
+	FieldNames
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// generator derives JSON Schema definitions from Go types.
+type generator struct {
+	defs map[string]any
+	// refPrefix is the prefix of the references to the definitions, e.g. #/$defs/
+	refPrefix string
+	naming    Naming
+}
+
+// nullable allows null in addition to the schema.
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []string{t, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+func (g *generator) typeSchema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if g.naming == FieldNames {
+			return nullable(g.typeSchema(t.Elem()))
+		}
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		schema := map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+		if g.naming == FieldNames {
+			return nullable(schema)
+		}
+		return schema
+	case reflect.Map:
+		schema := map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+		if g.naming == FieldNames {
+			return nullable(schema)
+		}
+		return schema
+	case reflect.Struct:
+		g.define(t)
+		return map[string]any{"$ref": g.refPrefix + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// addFields adds the properties of the fields of the struct type. The fields of embedded structs are only
+// required if the embedded struct is not a pointer, because encoding/json omits the fields of nil pointers.
+func (g *generator) addFields(t reflect.Type, properties map[string]any, required []string, embeddedPointer bool) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if g.naming == FieldNames {
+			if field.Anonymous {
+				if ft := field.Type; ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct {
+					required = g.addFields(ft.Elem(), properties, required, true)
+					continue
+				} else if ft.Kind() == reflect.Struct {
+					required = g.addFields(ft, properties, required, embeddedPointer)
+					continue
+				}
+			}
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			name = field.Name
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := g.typeSchema(field.Type)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+		if opts != "omitempty" && !embeddedPointer {
+			required = append(required, name)
+		}
+	}
+	return required
+}
+
+func (g *generator) define(t reflect.Type) {
+	if _, ok := g.defs[t.Name()]; ok {
+		return
+	}
+	properties := make(map[string]any)
+	// the definition is registered before the fields are processed to support recursive types
+	def := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	g.defs[t.Name()] = def
+	def["required"] = g.addFields(t, properties, make([]string, 0), false)
+}
+
+// Definitions returns the JSON Schema definitions of the struct types, keyed by type name. The definitions
+// reference each other with the given prefix, e.g. #/components/schemas/ for OpenAPI documents.
+func Definitions(types []any, refPrefix string, naming Naming) map[string]any {
+	g := &generator{defs: make(map[string]any), refPrefix: refPrefix, naming: naming}
+	for _, v := range types {
+		g.define(reflect.TypeOf(v))
+	}
+	return g.defs
+}
@@ -11,6 +11,10 @@ var (
 	CounterSemRelDownloads = stats.Int64("semrel_downloads", "Number of semantic-release downloads", "1")
 	CounterCacheHit        = stats.Int64("cache_hits", "Number of cache hits", "1")
 	CounterCacheMiss       = stats.Int64("cache_misses", "Number of cache misses", "1")
+	CounterBlobCacheHit    = stats.Int64("blob_cache_hits", "Number of blob cache hits", "1")
+	CounterBlobCacheMiss   = stats.Int64("blob_cache_misses", "Number of blob cache misses", "1")
+	CounterBlobCacheEvict  = stats.Int64("blob_cache_evictions", "Number of evicted blobs", "1")
+	GaugeBlobCacheSize     = stats.Int64("blob_cache_size", "Total size of the blob cache", stats.UnitBytes)
 
 	TagOSArch         = tag.MustNewKey("os_arch")
 	TagCacheKey       = tag.MustNewKey("cache_key")
@@ -39,6 +43,30 @@ var views = []*view.View{
 		TagKeys:     []tag.Key{TagCacheKey, TagCacheKeyPrefix},
 		Aggregation: view.Count(),
 	},
+	{
+		Name:        "blob_cache_hits",
+		Measure:     CounterBlobCacheHit,
+		Description: "Number of blob cache hits",
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "blob_cache_misses",
+		Measure:     CounterBlobCacheMiss,
+		Description: "Number of blob cache misses",
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "blob_cache_evictions",
+		Measure:     CounterBlobCacheEvict,
+		Description: "Number of evicted blobs",
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "blob_cache_size",
+		Measure:     GaugeBlobCacheSize,
+		Description: "Total size of the blob cache",
+		Aggregation: view.LastValue(),
+	},
 }
 
 func NewExporter(opt stackdriver.Options) (*stackdriver.Exporter, error) {
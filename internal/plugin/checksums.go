@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// maxFetchedAssetSize limits the size of checksum files, signatures and attestations.
+const maxFetchedAssetSize = 4 << 20
+
+type assetChecksum struct {
+	Checksum  string
+	Algorithm registry.ChecksumAlgorithm
+}
+
+var (
+	checksumFileRe = regexp.MustCompile(`(?i)(checksums\.txt|checksums\.sha(256|512)|sha(256|512)sums(\.txt)?)$`)
+	sidecarRe      = regexp.MustCompile(`(?i)^(.+)\.(sha256|sha512)$`)
+	// BSD style: SHA256 (file) = checksum
+	bsdChecksumRe = regexp.MustCompile(`(?i)^(SHA-?256|SHA-?512) \((.+)\) ?= ?([0-9a-f]+)$`)
+)
+
+func fetchAsset(ctx context.Context, url string) ([]byte, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := getDefaultRetryableClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status code %d", url, res.StatusCode)
+	}
+	content, err := io.ReadAll(io.LimitReader(res.Body, maxFetchedAssetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxFetchedAssetSize {
+		return nil, fmt.Errorf("failed to fetch %s: file is larger than %d bytes", url, maxFetchedAssetSize)
+	}
+	return content, nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return s != "" && err == nil
+}
+
+func algorithmFromTag(tag string) registry.ChecksumAlgorithm {
+	return registry.ChecksumAlgorithm(strings.ReplaceAll(strings.ToLower(tag), "-", ""))
+}
+
+// parseChecksumLine parses a line in the GNU coreutils (checksum  file, checksum *file) or the BSD (SHA256 (file) = checksum)
+// format. The file name is empty for lines that only contain a checksum. Checksums of unsupported algorithms are skipped.
+func parseChecksumLine(line string) (string, *assetChecksum, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, false
+	}
+	if m := bsdChecksumRe.FindStringSubmatch(line); m != nil {
+		checksum := strings.ToLower(m[3])
+		// the length of the checksum must match the algorithm of the tag
+		if algorithm, err := registry.ChecksumAlgorithmFromLength(checksum); err != nil || algorithm != algorithmFromTag(m[1]) {
+			return "", nil, false
+		}
+		return path.Base(m[2]), &assetChecksum{Checksum: checksum, Algorithm: algorithmFromTag(m[1])}, true
+	}
+	checksum, name, _ := strings.Cut(strings.ReplaceAll(line, "\t", " "), " ")
+	if !isHex(checksum) {
+		return "", nil, false
+	}
+	// the asterisk marks files that have been read in binary mode
+	name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+	if name != "" {
+		name = path.Base(name)
+	}
+	checksum = strings.ToLower(checksum)
+	algorithm, err := registry.ChecksumAlgorithmFromLength(checksum)
+	if err != nil {
+		return "", nil, false
+	}
+	return name, &assetChecksum{Checksum: checksum, Algorithm: algorithm}, true
+}
+
+// parseChecksumFile parses a checksum file and returns the checksums by lower case file name.
+func parseChecksumFile(checksums []byte) map[string]*assetChecksum {
+	ret := make(map[string]*assetChecksum)
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		name, c, ok := parseChecksumLine(scanner.Text())
+		if !ok || name == "" {
+			continue
+		}
+		ret[strings.ToLower(name)] = c
+	}
+	return ret
+}
+
+func fetchChecksumFile(ctx context.Context, url string) (map[string]*assetChecksum, error) {
+	checksums, err := fetchAsset(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return parseChecksumFile(checksums), nil
+}
+
+func isChecksumFile(asset *github.ReleaseAsset) bool {
+	// signatures of the checksum file (e.g. checksums.txt.sig) are not checksum files
+	return asset.GetSize() <= maxFetchedAssetSize && checksumFileRe.MatchString(asset.GetName())
+}
+
+// getSidecarTarget returns the name of the asset that a checksum sidecar file (e.g. plugin_linux_amd64.sha256) belongs to.
+func getSidecarTarget(name string) (string, registry.ChecksumAlgorithm, bool) {
+	m := sidecarRe.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], algorithmFromTag(m[2]), true
+}
+
+func fetchSidecarChecksum(ctx context.Context, sidecar *github.ReleaseAsset) (*assetChecksum, error) {
+	_, algorithm, _ := getSidecarTarget(sidecar.GetName())
+	content, err := fetchAsset(ctx, sidecar.GetBrowserDownloadURL())
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		_, c, ok := parseChecksumLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if c.Algorithm != algorithm {
+			return nil, fmt.Errorf("checksum in %s is not a %s checksum", sidecar.GetName(), algorithm)
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("%s does not contain a checksum", sidecar.GetName())
+}
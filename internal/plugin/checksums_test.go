@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSHA256 = "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd"
+	testSHA512 = "ee8f9a1e1ee1c4d2ce6fdd3b2d8e7bb6e7e6e94ebf1b33e9ac46f0e87ee4b8ba2b9571e8e7a5d2d3e1dcf2dd6bde07a62e0ef3cf68fa1b3c9fb7b4bd4d0d91b8"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		testSHA256 + "  plugin_linux_amd64",
+		testSHA256 + " *plugin_linux_arm64",
+		strings.ToUpper(testSHA256) + "\tplugin_darwin_amd64",
+		testSHA512 + "  ./dist/plugin_darwin_arm64",
+		"SHA256 (plugin_windows_amd64.exe) = " + testSHA256,
+		"SHA512 (plugin_windows_arm64.exe) = " + testSHA512,
+		// MD5, SHA-1 and mislabeled checksums are not supported
+		"d41d8cd98f00b204e9800998ecf8427e  plugin_freebsd_amd64",
+		"da39a3ee5e6b4b0d3255bfef95601890afd80709  plugin_freebsd_arm64",
+		"SHA512 (plugin_linux_386) = " + testSHA256,
+		"invalid line",
+		"",
+	}, "\r\n")
+	checksums := parseChecksumFile([]byte(content))
+	require.Len(t, checksums, 6)
+	for _, name := range []string{"plugin_linux_amd64", "plugin_linux_arm64", "plugin_darwin_amd64", "plugin_windows_amd64.exe"} {
+		require.Equal(t, &assetChecksum{Checksum: testSHA256, Algorithm: registry.ChecksumAlgorithmSHA256}, checksums[name], name)
+	}
+	for _, name := range []string{"plugin_darwin_arm64", "plugin_windows_arm64.exe"} {
+		require.Equal(t, &assetChecksum{Checksum: testSHA512, Algorithm: registry.ChecksumAlgorithmSHA512}, checksums[name], name)
+	}
+}
+
+func TestIsChecksumFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		size     int
+		expected bool
+	}{
+		{"checksums.txt", 100, true},
+		{"plugin_1.0.0_checksums.txt", 100, true},
+		{"SHA256SUMS", 100, true},
+		{"sha512sums.txt", 100, true},
+		{"checksums.sha512", 100, true},
+		{"checksums.txt", 64 * 1024, true},
+		{"checksums.txt", maxFetchedAssetSize + 1, false},
+		{"checksums.txt.sig", 100, false},
+		{"plugin_linux_amd64", 100, false},
+	}
+	for _, tc := range testCases {
+		asset := &github.ReleaseAsset{Name: github.String(tc.name), Size: github.Int(tc.size)}
+		require.Equal(t, tc.expected, isChecksumFile(asset), tc.name)
+	}
+}
+
+func TestGetPluginAssetsWithSidecars(t *testing.T) {
+	files := map[string]string{
+		"plugin_linux_amd64.sha256":   testSHA256 + "  plugin_linux_amd64\n",
+		"plugin_darwin_arm64.sha512":  testSHA512 + "\n",
+		"plugin_windows_amd64.sha512": testSHA256 + "\n",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(files[r.URL.Path[1:]]))
+	}))
+	defer ts.Close()
+	newAsset := func(name string) *github.ReleaseAsset {
+		return &github.ReleaseAsset{Name: github.String(name), Size: github.Int(100), BrowserDownloadURL: github.String(fmt.Sprintf("%s/%s", ts.URL, name))}
+	}
+
+	assets, err := getPluginAssets(context.Background(), newTestGitHubRelease(
+		newAsset("plugin_linux_amd64"),
+		newAsset("plugin_linux_amd64.sha256"),
+		newAsset("plugin_darwin_arm64"),
+		newAsset("plugin_darwin_arm64.sha512"),
+		newAsset("plugin_linux_arm"),
+	), nil)
+	require.NoError(t, err)
+	require.Len(t, assets, 3)
+	require.Equal(t, testSHA256, assets["linux/amd64"].Checksum)
+	require.Equal(t, registry.ChecksumAlgorithmSHA256, assets["linux/amd64"].ChecksumAlgorithm)
+	require.Equal(t, testSHA512, assets["darwin/arm64"].Checksum)
+	require.Equal(t, registry.ChecksumAlgorithmSHA512, assets["darwin/arm64"].ChecksumAlgorithm)
+	require.Empty(t, assets["linux/arm"].Checksum)
+
+	_, err = getPluginAssets(context.Background(), newTestGitHubRelease(
+		newAsset("plugin_windows_amd64"),
+		newAsset("plugin_windows_amd64.sha512"),
+	), nil)
+	require.ErrorContains(t, err, "is not a sha512 checksum")
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+)
+
+// releaseMetadataAssetName is the name of the optional release asset that declares the compatibility of a release.
+const releaseMetadataAssetName = "plugin-metadata.json"
+
+// Compatibility declares the versions of semantic-release and the plugins that are compatible with a range
+// of plugin versions.
+type Compatibility struct {
+	// Versions is the constraint of the plugin versions the entry applies to, e.g. ">= 2.0.0".
+	Versions string
+	// SemanticRelease is the constraint of the compatible semantic-release versions, e.g. ">= 2.20.0".
+	SemanticRelease string
+	// Requires and Conflicts list other plugins in the format <full name>[@<version constraint>],
+	// e.g. provider-github@^1.0.0.
+	Requires  []string
+	Conflicts []string
+}
+
+// releaseMetadata is the content of the plugin-metadata.json asset.
+type releaseMetadata struct {
+	SemanticRelease string   `json:"semanticRelease"`
+	Requires        []string `json:"requires"`
+	Conflicts       []string `json:"conflicts"`
+}
+
+func parsePluginRelations(relations []string) ([]*registry.PluginRelation, error) {
+	if len(relations) == 0 {
+		return nil, nil
+	}
+	ret := make([]*registry.PluginRelation, len(relations))
+	for i, r := range relations {
+		relation, err := registry.ParsePluginRelation(r)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = relation
+	}
+	return ret, nil
+}
+
+// setCompatibility validates the declared compatibility and sets it on the release.
+func setCompatibility(pr *registry.PluginRelease, semanticRelease string, requires, conflicts []string) error {
+	semRelConstraint := ""
+	if semanticRelease != "" {
+		constraint, err := semver.NewConstraint(semanticRelease)
+		if err != nil {
+			return fmt.Errorf("invalid semantic-release constraint: %w", err)
+		}
+		semRelConstraint = constraint.String()
+	}
+	requiredPlugins, err := parsePluginRelations(requires)
+	if err != nil {
+		return err
+	}
+	conflictingPlugins, err := parsePluginRelations(conflicts)
+	if err != nil {
+		return err
+	}
+	pr.SemanticReleaseConstraint = semRelConstraint
+	pr.Requires = requiredPlugins
+	pr.Conflicts = conflictingPlugins
+	return nil
+}
+
+// applyReleaseMetadata reads the compatibility of the release from its plugin-metadata.json asset.
+// The release is left unchanged if it does not provide the asset.
+func applyReleaseMetadata(ctx context.Context, pr *registry.PluginRelease, ghAssets []*github.ReleaseAsset) error {
+	for _, asset := range ghAssets {
+		if asset.GetName() != releaseMetadataAssetName {
+			continue
+		}
+		if asset.GetSize() > maxFetchedAssetSize {
+			return fmt.Errorf("%s is larger than %d bytes", releaseMetadataAssetName, maxFetchedAssetSize)
+		}
+		content, err := fetchAsset(ctx, asset.GetBrowserDownloadURL())
+		if err != nil {
+			return err
+		}
+		var metadata releaseMetadata
+		if err := json.Unmarshal(content, &metadata); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", releaseMetadataAssetName, err)
+		}
+		if err := setCompatibility(pr, metadata.SemanticRelease, metadata.Requires, metadata.Conflicts); err != nil {
+			return fmt.Errorf("invalid %s: %w", releaseMetadataAssetName, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// applyCompatibility overrides the compatibility of the release with the first catalog entry that matches
+// the version of the release. Invalid catalog entries are ignored.
+func (p *Plugin) applyCompatibility(pr *registry.PluginRelease) {
+	version, err := semver.NewVersion(pr.Version)
+	if err != nil {
+		return
+	}
+	for _, c := range p.Compatibility {
+		versions, err := semver.NewConstraint(c.Versions)
+		if err != nil || !versions.Check(version) {
+			continue
+		}
+		_ = setCompatibility(pr, c.SemanticRelease, c.Requires, c.Conflicts)
+		return
+	}
+}
+
+// findCompatibleRelease returns the newest stored release that matches the constraint and is compatible with
+// the version of semantic-release.
+func (p *Plugin) findCompatibleRelease(ctx context.Context, db *firestore.Client, constraint *semver.Constraints, semRelVersion *semver.Version) (*registry.PluginRelease, error) {
+	storedReleases, err := p.getStoredReleases(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases: %w", err)
+	}
+	versions := make([]string, 0, len(storedReleases))
+	for version, release := range storedReleases {
+		if release.IsCompatible(semRelVersion) {
+			versions = append(versions, version)
+		}
+	}
+	matchingVersion, err := findMatchingVersion(versions, constraint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find version compatible with semantic-release %s: %w", semRelVersion, err)
+	}
+	return storedReleases[matchingVersion], nil
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyReleaseMetadata(t *testing.T) {
+	files := map[string]string{
+		"/valid":            `{"semanticRelease": ">=2.20.0", "requires": ["provider-github@^1.0.0"], "conflicts": ["hooks-npm-binary-releaser"]}`,
+		"/empty":            `{}`,
+		"/invalid":          `{"semanticRelease": "not a constraint"}`,
+		"/invalid-relation": `{"requires": ["github"]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, files[r.URL.Path])
+	}))
+	defer ts.Close()
+	newAssets := func(path string) []*github.ReleaseAsset {
+		return []*github.ReleaseAsset{
+			{Name: github.String("plugin_linux_amd64"), BrowserDownloadURL: github.String(ts.URL + "/binary")},
+			{Name: github.String(releaseMetadataAssetName), BrowserDownloadURL: github.String(ts.URL + path)},
+		}
+	}
+
+	pr := &registry.PluginRelease{}
+	require.NoError(t, applyReleaseMetadata(context.Background(), pr, newAssets("/valid")))
+	require.Equal(t, ">=2.20.0", pr.SemanticReleaseConstraint)
+	require.Equal(t, []*registry.PluginRelation{{FullName: "provider-github", VersionConstraint: "^1.0.0"}}, pr.Requires)
+	require.Equal(t, []*registry.PluginRelation{{FullName: "hooks-npm-binary-releaser"}}, pr.Conflicts)
+
+	require.NoError(t, applyReleaseMetadata(context.Background(), pr, newAssets("/valid")[:1]))
+	require.Equal(t, ">=2.20.0", pr.SemanticReleaseConstraint)
+
+	require.NoError(t, applyReleaseMetadata(context.Background(), pr, newAssets("/empty")))
+	require.Empty(t, pr.SemanticReleaseConstraint)
+	require.Empty(t, pr.Requires)
+	require.Empty(t, pr.Conflicts)
+
+	err := applyReleaseMetadata(context.Background(), pr, newAssets("/invalid"))
+	require.ErrorContains(t, err, "invalid semantic-release constraint")
+	err = applyReleaseMetadata(context.Background(), pr, newAssets("/invalid-relation"))
+	require.ErrorContains(t, err, "invalid plugin name")
+}
+
+func TestApplyCompatibility(t *testing.T) {
+	p := &Plugin{Compatibility: []*Compatibility{
+		{Versions: ">= 3.0.0", SemanticRelease: ">= 2.30.0", Requires: []string{"provider-github@>= 2.0.0"}},
+		{Versions: ">= 2.0.0", SemanticRelease: ">= 2.20.0"},
+	}}
+	testCases := []struct {
+		version  string
+		declared string
+		expected string
+	}{
+		{"3.1.0", "", ">=2.30.0"},
+		{"2.5.0", ">= 2.21.0", ">=2.20.0"},
+		{"1.0.0", ">= 2.10.0", ">= 2.10.0"},
+		{"1.0.0", "", ""},
+	}
+	for _, tc := range testCases {
+		pr := &registry.PluginRelease{Version: tc.version, SemanticReleaseConstraint: tc.declared}
+		p.applyCompatibility(pr)
+		require.Equal(t, tc.expected, pr.SemanticReleaseConstraint, tc.version)
+	}
+
+	pr := &registry.PluginRelease{Version: "3.0.0"}
+	p.applyCompatibility(pr)
+	require.Equal(t, []*registry.PluginRelation{{FullName: "provider-github", VersionConstraint: ">=2.0.0"}}, pr.Requires)
+}
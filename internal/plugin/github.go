@@ -2,10 +2,12 @@ package plugin
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +16,8 @@ import (
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/google/go-github/v59/github"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -39,16 +43,96 @@ func getOwnerRepo(fullRepo string) (string, string) {
 	return owner, repo
 }
 
-func getAllGitHubReleases(ctx context.Context, ghClient *github.Client, fullRepo string) ([]*github.RepositoryRelease, error) {
+// repositoryMetadata is the plugin metadata that is derived from the GitHub repository.
+type repositoryMetadata struct {
+	License     string
+	Homepage    string
+	Maintainers []string
+	Keywords    []string
+}
+
+func toRepositoryMetadata(repo *github.Repository) *repositoryMetadata {
+	metadata := &repositoryMetadata{
+		Homepage: repo.GetHomepage(),
+		Keywords: repo.Topics,
+	}
+	// GitHub reports NOASSERTION if the license could not be detected
+	if spdxID := repo.GetLicense().GetSPDXID(); spdxID != "" && spdxID != "NOASSERTION" {
+		metadata.License = spdxID
+	}
+	// organizations are not listed as maintainers
+	if repo.GetOwner().GetType() == "User" {
+		metadata.Maintainers = []string{repo.GetOwner().GetLogin()}
+	}
+	return metadata
+}
+
+func getRepositoryMetadata(ctx context.Context, ghClient *github.Client, fullRepo string) (*repositoryMetadata, error) {
 	owner, repo := getOwnerRepo(fullRepo)
-	ret := make([]*github.RepositoryRelease, 0)
-	opts := &github.ListOptions{Page: 1, PerPage: 100}
+	ghRepo, _, err := ghClient.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return toRepositoryMetadata(ghRepo), nil
+}
+
+// gitHubRelease is a GitHub release with the digests of its assets, which are not supported by the GitHub client.
+type gitHubRelease struct {
+	*github.RepositoryRelease
+	// assetDigests contains the digests (e.g. sha256:<checksum>) by asset ID
+	assetDigests map[int64]string
+}
+
+type gitHubReleaseAssetDigests struct {
+	Assets []struct {
+		ID     int64  `json:"id"`
+		Digest string `json:"digest"`
+	} `json:"assets"`
+}
+
+func decodeGitHubRelease(raw json.RawMessage) (*gitHubRelease, error) {
+	release := &gitHubRelease{RepositoryRelease: &github.RepositoryRelease{}, assetDigests: make(map[int64]string)}
+	if err := json.Unmarshal(raw, release.RepositoryRelease); err != nil {
+		return nil, err
+	}
+	var digests gitHubReleaseAssetDigests
+	if err := json.Unmarshal(raw, &digests); err != nil {
+		return nil, err
+	}
+	for _, asset := range digests.Assets {
+		if asset.Digest != "" {
+			release.assetDigests[asset.ID] = asset.Digest
+		}
+	}
+	return release, nil
+}
+
+func (r *gitHubRelease) getAssetDigest(asset *github.ReleaseAsset) string {
+	if r.assetDigests == nil {
+		return ""
+	}
+	return r.assetDigests[asset.GetID()]
+}
+
+func getAllGitHubReleases(ctx context.Context, ghClient *github.Client, fullRepo string) ([]*gitHubRelease, error) {
+	owner, repo := getOwnerRepo(fullRepo)
+	ret := make([]*gitHubRelease, 0)
+	page := 1
 	for {
-		releases, resp, err := ghClient.Repositories.ListReleases(ctx, owner, repo, opts)
+		req, err := ghClient.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/releases?page=%d&per_page=100", owner, repo, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var rawReleases []json.RawMessage
+		resp, err := ghClient.Do(ctx, req, &rawReleases)
 		if err != nil {
 			return nil, err
 		}
-		for _, release := range releases {
+		for _, rawRelease := range rawReleases {
+			release, err := decodeGitHubRelease(rawRelease)
+			if err != nil {
+				return nil, err
+			}
 			// ignore drafts
 			if release.GetDraft() {
 				continue
@@ -68,14 +152,22 @@ func getAllGitHubReleases(ctx context.Context, ghClient *github.Client, fullRepo
 		if resp.NextPage == 0 {
 			break
 		}
-		opts.Page = resp.NextPage
+		page = resp.NextPage
 	}
 	return ret, nil
 }
 
-func getGitHubRelease(ctx context.Context, ghClient *github.Client, fullRepo, tag string) (*github.RepositoryRelease, error) {
+func getGitHubRelease(ctx context.Context, ghClient *github.Client, fullRepo, tag string) (*gitHubRelease, error) {
 	owner, repo := getOwnerRepo(fullRepo)
-	release, _, err := ghClient.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	req, err := ghClient.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/releases/tags/%s", owner, repo, tag), nil)
+	if err != nil {
+		return nil, err
+	}
+	var rawRelease json.RawMessage
+	if _, err := ghClient.Do(ctx, req, &rawRelease); err != nil {
+		return nil, err
+	}
+	release, err := decodeGitHubRelease(rawRelease)
 	if err != nil {
 		return nil, err
 	}
@@ -91,39 +183,17 @@ func getGitHubRelease(ctx context.Context, ghClient *github.Client, fullRepo, ta
 	return release, nil
 }
 
-func fetchChecksumFile(ctx context.Context, url string) (map[string]string, error) {
-	ret := make(map[string]string)
-	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	res, err := getDefaultRetryableClient().Do(req)
-	if err != nil {
-		return nil, err
-	}
-	checksums, err := io.ReadAll(res.Body)
-	_ = res.Body.Close()
+func getPluginAssets(ctx context.Context, ghr *gitHubRelease, matching *AssetMatching) (map[string]*registry.PluginAsset, error) {
+	matcher, err := matching.newMatcher()
 	if err != nil {
 		return nil, err
 	}
-	for _, l := range strings.Split(string(checksums), "\n") {
-		sl := strings.Split(l, " ")
-		if len(sl) < 3 {
-			continue
-		}
-		ret[strings.ToLower(sl[2])] = sl[0]
-	}
-	return ret, nil
-}
-
-var osArchRe = regexp.MustCompile(`(?i)(aix|android|darwin|dragonfly|freebsd|hurd|illumos|js|linux|nacl|netbsd|openbsd|plan9|solaris|windows|zos)(_|-)(386|amd64|amd64p32|arm|armbe|arm64|arm64be|ppc64|ppc64le|mips|mipsle|mips64|mips64le|mips64p32|mips64p32le|ppc|riscv|riscv64|s390|s390x|sparc|sparc64|wasm)(\.exe)?$`)
-
-func getPluginAssets(ctx context.Context, gha []*github.ReleaseAsset) (map[string]*registry.PluginAsset, error) {
 	assets := make([]*registry.PluginAsset, 0)
-	var checksumMap map[string]string
-	for _, asset := range gha {
+	var checksumMap map[string]*assetChecksum
+	sidecars := make(map[string]*github.ReleaseAsset)
+	for _, asset := range ghr.Assets {
 		fn := asset.GetName()
-		if checksumMap == nil && asset.GetSize() <= 4096 && strings.Contains(strings.ToLower(fn), "checksums.txt") {
+		if checksumMap == nil && isChecksumFile(asset) {
 			csMap, err := fetchChecksumFile(ctx, asset.GetBrowserDownloadURL())
 			if err != nil {
 				return nil, err
@@ -131,39 +201,147 @@ func getPluginAssets(ctx context.Context, gha []*github.ReleaseAsset) (map[strin
 			checksumMap = csMap
 			continue
 		}
+		if target, _, ok := getSidecarTarget(fn); ok {
+			sidecars[strings.ToLower(target)] = asset
+			continue
+		}
 		assets = append(assets, &registry.PluginAsset{
-			FileName: fn,
-			URL:      asset.GetBrowserDownloadURL(),
+			FileName:      fn,
+			URL:           asset.GetBrowserDownloadURL(),
+			Size:          int64(asset.GetSize()),
+			ContentType:   asset.GetContentType(),
+			Digest:        ghr.getAssetDigest(asset),
+			DownloadCount: asset.GetDownloadCount(),
 		})
 	}
 
 	ret := make(map[string]*registry.PluginAsset)
 	for _, pa := range assets {
-		osArch := osArchRe.FindAllStringSubmatch(pa.FileName, -1)
-		if len(osArch) < 1 || len(osArch[0]) < 4 {
+		p := matcher.match(pa.FileName)
+		if p == nil {
 			continue
 		}
-		if checksumMap != nil {
-			pa.Checksum = checksumMap[strings.ToLower(pa.FileName)]
+		if c := checksumMap[strings.ToLower(pa.FileName)]; c != nil {
+			pa.Checksum, pa.ChecksumAlgorithm = c.Checksum, c.Algorithm
+		} else if sidecar := sidecars[strings.ToLower(pa.FileName)]; sidecar != nil {
+			c, err := fetchSidecarChecksum(ctx, sidecar)
+			if err != nil {
+				return nil, err
+			}
+			pa.Checksum, pa.ChecksumAlgorithm = c.Checksum, c.Algorithm
+		}
+		pa.OS = p.OS
+		pa.Arch = p.Arch
+		pa.Variant = p.Variant
+		pa.ArchiveType, _ = registry.ArchiveTypeFromFileName(pa.FileName)
+		osArchKey := p.String()
+		// raw binaries are preferred over archives of the same binary
+		if existing := ret[osArchKey]; existing != nil && existing.ArchiveType == "" && pa.ArchiveType != "" {
+			continue
 		}
-		os, arch := strings.ToLower(osArch[0][1]), strings.ToLower(osArch[0][3])
-		pa.OS = os
-		pa.Arch = arch
-		ret[fmt.Sprintf("%s/%s", os, arch)] = pa
+		ret[osArchKey] = pa
 	}
 	return ret, nil
 }
 
-func toPluginRelease(ctx context.Context, ghr *github.RepositoryRelease) (*registry.PluginRelease, error) {
-	assets, err := getPluginAssets(ctx, ghr.Assets)
+// computeChecksum downloads the asset and returns its SHA-256 checksum and size.
+func computeChecksum(ctx context.Context, url string) (string, int64, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	res, err := getDefaultRetryableClient().Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("failed to fetch %s: unexpected status code %d", url, res.StatusCode)
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, res.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// computeMissingChecksums computes the checksums of all assets that have no checksum. Checksums that have been
+// computed for the same asset URL during a previous ingestion are reused, so every asset is downloaded only once.
+// It reports whether any checksum has been computed by the registry.
+func computeMissingChecksums(ctx context.Context, assets map[string]*registry.PluginAsset, previous *registry.PluginRelease) (bool, error) {
+	computed := false
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(4)
+	for osArch, pa := range assets {
+		if pa.Checksum != "" {
+			continue
+		}
+		computed = true
+		if previous != nil && previous.RegistryComputedChecksums {
+			if prevAsset := previous.Assets[osArch]; prevAsset != nil && prevAsset.URL == pa.URL && prevAsset.Checksum != "" {
+				pa.Checksum = prevAsset.Checksum
+				pa.ChecksumAlgorithm = prevAsset.GetChecksumAlgorithm()
+				continue
+			}
+		}
+		errGroup.Go(func() error {
+			checksum, size, err := computeChecksum(groupCtx, pa.URL)
+			if err != nil {
+				return fmt.Errorf("failed to compute checksum of %s: %w", pa.FileName, err)
+			}
+			if pa.Size > 0 && pa.Size != size {
+				return fmt.Errorf("failed to compute checksum of %s: unexpected size %d (should be %d)", pa.FileName, size, pa.Size)
+			}
+			pa.Checksum = checksum
+			pa.ChecksumAlgorithm = registry.ChecksumAlgorithmSHA256
+			pa.Size = size
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		return false, err
+	}
+	return computed, nil
+}
+
+// toPluginRelease converts the GitHub release. The previously stored release is optional and is used to
+// reuse the checksums that have been computed by the registry.
+func (p *Plugin) toPluginRelease(ctx context.Context, log *logrus.Entry, ghr *gitHubRelease, previous *registry.PluginRelease) (*registry.PluginRelease, error) {
+	assets, err := getPluginAssets(ctx, ghr, p.AssetMatching)
 	if err != nil {
 		return nil, err
 	}
 
-	return &registry.PluginRelease{
+	pr := &registry.PluginRelease{
 		Version:    semver.MustParse(ghr.GetTagName()).String(),
 		Prerelease: ghr.GetPrerelease(),
 		CreatedAt:  ghr.GetCreatedAt().Time,
 		Assets:     assets,
-	}, nil
+		// the release notes are stored, so that upgrades can be reviewed without querying GitHub
+		HTMLURL:      ghr.GetHTMLURL(),
+		ReleaseNotes: ghr.GetBody(),
+	}
+	if p.Verification != nil {
+		// the signature covers the checksums of the release, so it is verified before any checksum is computed
+		pr.Verification, err = p.Verification.verifyRelease(ctx, p.Repo, ghr.Assets, assets)
+		if err != nil {
+			return nil, releaseVerificationError(pr.Version, err)
+		}
+	}
+	// the declared compatibility is optional, so a broken metadata file does not stop the ingestion
+	if mErr := applyReleaseMetadata(ctx, pr, ghr.Assets); mErr != nil {
+		log.Warnf("skipping the release metadata of %s@%s: %v", p.GetFullName(), pr.Version, mErr)
+	}
+	pr.RegistryComputedChecksums, err = computeMissingChecksums(ctx, assets, previous)
+	if err != nil {
+		return nil, err
+	}
+	if p.Verification != nil {
+		err = p.Verification.verifyReleaseProvenance(ctx, p.Repo, ghr.Assets, assets, pr.Verification)
+		if err != nil {
+			return nil, releaseVerificationError(pr.Version, err)
+		}
+	}
+	return pr, nil
 }
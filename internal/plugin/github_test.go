@@ -5,19 +5,63 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/google/go-github/v59/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
 )
 
+func newTestLogger() *logrus.Entry {
+	log, _ := test.NewNullLogger()
+	return logrus.NewEntry(log)
+}
+
+func newTestGitHubRelease(assets ...*github.ReleaseAsset) *gitHubRelease {
+	return &gitHubRelease{RepositoryRelease: &github.RepositoryRelease{Assets: assets}}
+}
+
 func TestGetOwnerRepo(t *testing.T) {
 	owner, repo := getOwnerRepo("owner/repo")
 	require.Equal(t, "owner", owner)
 	require.Equal(t, "repo", repo)
 }
 
+func TestGetRepositoryMetadata(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposByOwnerByRepo,
+			&github.Repository{
+				Homepage: github.String("https://example.com"),
+				Topics:   []string{"semantic-release", "plugin"},
+				License:  &github.License{SPDXID: github.String("MIT")},
+				Owner:    &github.User{Login: github.String("octocat"), Type: github.String("User")},
+			},
+			&github.Repository{
+				License: &github.License{SPDXID: github.String("NOASSERTION")},
+				Owner:   &github.User{Login: github.String("go-semantic-release"), Type: github.String("Organization")},
+			},
+		),
+	)
+	ghClient := github.NewClient(mockedHTTPClient)
+	metadata, err := getRepositoryMetadata(context.Background(), ghClient, "octocat/repo")
+	require.NoError(t, err)
+	require.Equal(t, &repositoryMetadata{
+		License:     "MIT",
+		Homepage:    "https://example.com",
+		Maintainers: []string{"octocat"},
+		Keywords:    []string{"semantic-release", "plugin"},
+	}, metadata)
+
+	metadata, err = getRepositoryMetadata(context.Background(), ghClient, "go-semantic-release/repo")
+	require.NoError(t, err)
+	require.Equal(t, &repositoryMetadata{}, metadata)
+}
+
 func TestGetAllGitHubReleases(t *testing.T) {
 	mockedHTTPClient := mock.NewMockedHTTPClient(
 		mock.WithRequestMatch(
@@ -58,13 +102,52 @@ func TestGetGitHubRelease(t *testing.T) {
 	require.ErrorContains(t, err, "release is a draft")
 }
 
+func TestGetGitHubReleaseAssetMetadata(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesTagsByOwnerByRepoByTag,
+			map[string]any{
+				"tag_name": "v1.0.0",
+				"assets": []map[string]any{
+					{
+						"id":                   1,
+						"name":                 "plugin_v1.0.0_linux_amd64",
+						"size":                 6,
+						"content_type":         "application/octet-stream",
+						"download_count":       42,
+						"digest":               "sha256:9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd",
+						"browser_download_url": "https://example.com/plugin_v1.0.0_linux_amd64",
+					},
+					{"id": 2, "name": "plugin_v1.0.0_darwin_arm64", "size": 7},
+				},
+			},
+		),
+	)
+	ghRelease, err := getGitHubRelease(context.Background(), github.NewClient(mockedHTTPClient), "owner/repo", "v1.0.0")
+	require.NoError(t, err)
+	assets, err := getPluginAssets(context.Background(), ghRelease, nil)
+	require.NoError(t, err)
+	require.Equal(t, &registry.PluginAsset{
+		FileName:      "plugin_v1.0.0_linux_amd64",
+		URL:           "https://example.com/plugin_v1.0.0_linux_amd64",
+		OS:            "linux",
+		Arch:          "amd64",
+		Size:          6,
+		ContentType:   "application/octet-stream",
+		Digest:        "sha256:9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd",
+		DownloadCount: 42,
+	}, assets["linux/amd64"])
+	require.Equal(t, int64(7), assets["darwin/arm64"].Size)
+	require.Empty(t, assets["darwin/arm64"].Digest)
+}
+
 var testChecksumFile = `
-0911f3dd  plugin_v1.0.0_windows_amd64.exe
-0fe1a3ce  plugin_v1.0.0_darwin_amd64
-50681c38  plugin_v1.0.0_darwin_arm64
-8a491fb8  plugin_v1.0.0_linux_amd64
-c3703969  plugin_v1.0.0_linux_arm
-cacce75a  plugin_v1.0.0_linux_arm64
+0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd  plugin_v1.0.0_windows_amd64.exe
+0fe1a3ce0fe1a3ce0fe1a3ce0fe1a3ce0fe1a3ce0fe1a3ce0fe1a3ce0fe1a3ce  plugin_v1.0.0_darwin_amd64
+50681c3850681c3850681c3850681c3850681c3850681c3850681c3850681c38  plugin_v1.0.0_darwin_arm64
+8a491fb88a491fb88a491fb88a491fb88a491fb88a491fb88a491fb88a491fb8  plugin_v1.0.0_linux_amd64
+c3703969c3703969c3703969c3703969c3703969c3703969c3703969c3703969  plugin_v1.0.0_linux_arm
+cacce75acacce75acacce75acacce75acacce75acacce75acacce75acacce75a  plugin_v1.0.0_linux_arm64
 `
 
 func getCheckSumServer(failingRequests int) *httptest.Server {
@@ -85,8 +168,8 @@ func TestFetchChecksumFile(t *testing.T) {
 	checksums, err := fetchChecksumFile(context.Background(), ts.URL)
 	require.NoError(t, err)
 	require.Len(t, checksums, 6)
-	require.Equal(t, "0911f3dd", checksums["plugin_v1.0.0_windows_amd64.exe"])
-	require.Equal(t, "cacce75a", checksums["plugin_v1.0.0_linux_arm64"])
+	require.Equal(t, "0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd", checksums["plugin_v1.0.0_windows_amd64.exe"].Checksum)
+	require.Equal(t, "cacce75acacce75acacce75acacce75acacce75acacce75acacce75acacce75a", checksums["plugin_v1.0.0_linux_arm64"].Checksum)
 }
 
 func TestFetchChecksumFileRetry(t *testing.T) {
@@ -95,8 +178,8 @@ func TestFetchChecksumFileRetry(t *testing.T) {
 	checksums, err := fetchChecksumFile(context.Background(), ts.URL)
 	require.NoError(t, err)
 	require.Len(t, checksums, 6)
-	require.Equal(t, "0911f3dd", checksums["plugin_v1.0.0_windows_amd64.exe"])
-	require.Equal(t, "cacce75a", checksums["plugin_v1.0.0_linux_arm64"])
+	require.Equal(t, "0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd", checksums["plugin_v1.0.0_windows_amd64.exe"].Checksum)
+	require.Equal(t, "cacce75acacce75acacce75acacce75acacce75acacce75acacce75acacce75a", checksums["plugin_v1.0.0_linux_arm64"].Checksum)
 }
 
 func TestGetPluginAssets(t *testing.T) {
@@ -112,10 +195,105 @@ func TestGetPluginAssets(t *testing.T) {
 		{Name: github.String("plugin_v1.0.0_linux_arm64"), Size: github.Int(456), BrowserDownloadURL: dlURL},
 		{Name: github.String("checksums.txt"), Size: github.Int(789), BrowserDownloadURL: dlURL},
 	}
-	assets, err := getPluginAssets(context.Background(), ghReleaseAssets)
+	assets, err := getPluginAssets(context.Background(), newTestGitHubRelease(ghReleaseAssets...), nil)
 	require.NoError(t, err)
 	require.Len(t, assets, 6)
-	require.Equal(t, "0911f3dd", assets["windows/amd64"].Checksum)
-	require.Equal(t, "50681c38", assets["darwin/arm64"].Checksum)
-	require.Equal(t, "cacce75a", assets["linux/arm64"].Checksum)
+	require.Equal(t, "0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd0911f3dd", assets["windows/amd64"].Checksum)
+	require.Equal(t, "50681c3850681c3850681c3850681c3850681c3850681c3850681c3850681c38", assets["darwin/arm64"].Checksum)
+	require.Equal(t, "cacce75acacce75acacce75acacce75acacce75acacce75acacce75acacce75a", assets["linux/arm64"].Checksum)
+}
+
+func TestGetPluginAssetsArchived(t *testing.T) {
+	ghReleaseAssets := []*github.ReleaseAsset{
+		{Name: github.String("plugin_v1.0.0_linux_amd64.tar.gz"), Size: github.Int(123)},
+		{Name: github.String("plugin_v1.0.0_windows_amd64.zip"), Size: github.Int(234)},
+		{Name: github.String("plugin_v1.0.0_darwin_arm64.tgz"), Size: github.Int(345)},
+		{Name: github.String("plugin_v1.0.0_darwin_arm64"), Size: github.Int(456)},
+		{Name: github.String("plugin_v1.0.0_linux_arm64.tar.xz"), Size: github.Int(567)},
+	}
+	assets, err := getPluginAssets(context.Background(), newTestGitHubRelease(ghReleaseAssets...), nil)
+	require.NoError(t, err)
+	require.Len(t, assets, 3)
+	require.Equal(t, registry.ArchiveTypeTarGz, assets["linux/amd64"].ArchiveType)
+	require.Equal(t, registry.ArchiveTypeZip, assets["windows/amd64"].ArchiveType)
+	// the raw binary is preferred
+	require.Equal(t, "plugin_v1.0.0_darwin_arm64", assets["darwin/arm64"].FileName)
+	require.Empty(t, assets["darwin/arm64"].ArchiveType)
+}
+
+func TestToPluginReleaseComputesMissingChecksums(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = io.WriteString(w, "binary")
+	}))
+	defer ts.Close()
+	release := &github.RepositoryRelease{
+		TagName: github.String("v1.0.0"),
+		Assets: []*github.ReleaseAsset{
+			{Name: github.String("plugin_v1.0.0_linux_amd64"), Size: github.Int(6), BrowserDownloadURL: github.String(ts.URL + "/linux_amd64")},
+			{Name: github.String("plugin_v1.0.0_darwin_arm64"), Size: github.Int(6), BrowserDownloadURL: github.String(ts.URL + "/darwin_arm64")},
+		},
+	}
+	p := &Plugin{Type: "provider", Name: "test", Repo: "owner/repo"}
+	pr, err := p.toPluginRelease(context.Background(), newTestLogger(), &gitHubRelease{RepositoryRelease: release}, nil)
+	require.NoError(t, err)
+	require.True(t, pr.RegistryComputedChecksums)
+	require.Equal(t, "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd", pr.Assets["linux/amd64"].Checksum)
+	require.Equal(t, int64(6), pr.Assets["darwin/arm64"].Size)
+	require.Equal(t, int32(2), requests.Load())
+
+	// the computed checksums of the previous ingestion are reused
+	pr, err = p.toPluginRelease(context.Background(), newTestLogger(), &gitHubRelease{RepositoryRelease: release}, pr)
+	require.NoError(t, err)
+	require.True(t, pr.RegistryComputedChecksums)
+	require.Equal(t, "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd", pr.Assets["linux/amd64"].Checksum)
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestToPluginReleaseWithChecksumFile(t *testing.T) {
+	checksumServer := getCheckSumServer(0)
+	defer checksumServer.Close()
+	dlURL := github.String(checksumServer.URL)
+	release := &github.RepositoryRelease{
+		TagName: github.String("v1.0.0"),
+		HTMLURL: github.String("https://github.com/owner/repo/releases/tag/v1.0.0"),
+		Body:    github.String("## Bug Fixes\n* fix"),
+		Assets: []*github.ReleaseAsset{
+			{Name: github.String("plugin_v1.0.0_linux_amd64"), Size: github.Int(456), BrowserDownloadURL: dlURL},
+			{Name: github.String("checksums.txt"), Size: github.Int(789), BrowserDownloadURL: dlURL},
+		},
+	}
+	pr, err := (&Plugin{Repo: "owner/repo"}).toPluginRelease(context.Background(), newTestLogger(), &gitHubRelease{RepositoryRelease: release}, nil)
+	require.NoError(t, err)
+	require.False(t, pr.RegistryComputedChecksums)
+	require.Equal(t, "8a491fb88a491fb88a491fb88a491fb88a491fb88a491fb88a491fb88a491fb8", pr.Assets["linux/amd64"].Checksum)
+	require.Equal(t, "https://github.com/owner/repo/releases/tag/v1.0.0", pr.HTMLURL)
+	require.Equal(t, "## Bug Fixes\n* fix", pr.ReleaseNotes)
+}
+
+func TestToPluginReleaseSkipsBrokenMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+releaseMetadataAssetName {
+			_, _ = io.WriteString(w, "{invalid")
+			return
+		}
+		_, _ = io.WriteString(w, "binary")
+	}))
+	defer ts.Close()
+	release := &github.RepositoryRelease{
+		TagName: github.String("v1.0.0"),
+		Assets: []*github.ReleaseAsset{
+			{Name: github.String("plugin_v1.0.0_linux_amd64"), Size: github.Int(6), BrowserDownloadURL: github.String(ts.URL + "/linux_amd64")},
+			{Name: github.String(releaseMetadataAssetName), Size: github.Int(8), BrowserDownloadURL: github.String(ts.URL + "/" + releaseMetadataAssetName)},
+		},
+	}
+	log, hook := test.NewNullLogger()
+	pr, err := (&Plugin{Type: "provider", Name: "test", Repo: "owner/repo"}).toPluginRelease(context.Background(), logrus.NewEntry(log), &gitHubRelease{RepositoryRelease: release}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", pr.Version)
+	require.Empty(t, pr.SemanticReleaseConstraint)
+	require.Len(t, hook.Entries, 1)
+	require.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	require.Contains(t, hook.LastEntry().Message, "provider-test@1.0.0")
 }
@@ -0,0 +1,252 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+var (
+	knownOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "js", "linux", "nacl", "netbsd", "openbsd",
+		"plan9", "solaris", "windows", "zos",
+	}
+	knownArch = []string{
+		"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "ppc64", "ppc64le", "mips", "mipsle", "mips64",
+		"mips64le", "mips64p32", "mips64p32le", "ppc", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm",
+		// all is used for universal binaries, e.g. darwin/all
+		"all",
+	}
+
+	// defaultOSAliases maps common OS names to GOOS.
+	defaultOSAliases = map[string]string{
+		"macos": "darwin",
+		"osx":   "darwin",
+		"mac":   "darwin",
+		"win":   "windows",
+	}
+	// defaultArchAliases maps common architecture names to GOARCH, optionally followed by the GOARM variant.
+	defaultArchAliases = map[string]string{
+		"x86_64":    "amd64",
+		"x64":       "amd64",
+		"aarch64":   "arm64",
+		"i386":      "386",
+		"i686":      "386",
+		"x86":       "386",
+		"armv5":     "arm/v5",
+		"armv6":     "arm/v6",
+		"armv7":     "arm/v7",
+		"armhf":     "arm/v7",
+		"ppc64el":   "ppc64le",
+		"universal": "all",
+	}
+
+	armVariantRe = regexp.MustCompile(`^v?([5-7])$`)
+	// defaultAssetRe matches <os>_<arch>[_<arm variant>][.exe][.tar.gz|.tgz|.zip] at the end of asset names.
+	defaultAssetRe = newAssetRegexp(defaultOSAliases, defaultArchAliases)
+)
+
+// AssetMatching overrides how the assets of a plugin release are mapped to platforms.
+type AssetMatching struct {
+	// Patterns are regular expressions that are matched against the asset file names before the default pattern.
+	// They must contain the named groups os and arch and may contain the named group variant (GOARM version).
+	Patterns []string
+	// OSAliases and ArchAliases are consulted before the default alias tables. Arch aliases may contain
+	// the GOARM variant, e.g. "armv7l": "arm/v7".
+	OSAliases   map[string]string
+	ArchAliases map[string]string
+}
+
+// platform is a normalized GOOS/GOARCH combination with an optional GOARM variant (e.g. v7).
+type platform struct {
+	OS      string
+	Arch    string
+	Variant string
+}
+
+func (p *platform) String() string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Arch, p.Variant)
+	}
+	return fmt.Sprintf("%s/%s", p.OS, p.Arch)
+}
+
+func regexpAlternation(values []string, aliases map[string]string) string {
+	alternatives := append([]string{}, values...)
+	for alias := range aliases {
+		alternatives = append(alternatives, regexp.QuoteMeta(alias))
+	}
+	// longer alternatives first, so that e.g. arm64 is preferred over arm
+	sort.Slice(alternatives, func(i, j int) bool {
+		if len(alternatives[i]) != len(alternatives[j]) {
+			return len(alternatives[i]) > len(alternatives[j])
+		}
+		return alternatives[i] < alternatives[j]
+	})
+	return strings.Join(alternatives, "|")
+}
+
+func newAssetRegexp(osAliases, archAliases map[string]string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(
+		`(?i)(?:^|[_.-])(?P<os>%s)[_-](?P<arch>%s)(?:[_-]v?(?P<variant>[5-7]))?(?:\.exe)?(?:\.tar\.gz|\.tgz|\.zip)?$`,
+		regexpAlternation(knownOS, osAliases),
+		regexpAlternation(knownArch, archAliases),
+	))
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveAlias(aliases map[string]string, value string) string {
+	if resolved, ok := aliases[value]; ok {
+		return strings.ToLower(resolved)
+	}
+	return value
+}
+
+// normalizePlatform maps the matched names to GOOS, GOARCH and the GOARM variant.
+// It returns nil if the names do not describe a known platform.
+func (am *assetMatcher) normalizePlatform(osName, archName, variant string) *platform {
+	p := &platform{OS: resolveAlias(am.osAliases, strings.ToLower(osName))}
+	arch, aliasVariant, _ := strings.Cut(resolveAlias(am.archAliases, strings.ToLower(archName)), "/")
+	p.Arch = arch
+	if !containsString(knownOS, p.OS) || !containsString(knownArch, p.Arch) {
+		return nil
+	}
+	if variant == "" {
+		variant = aliasVariant
+	}
+	// variants are only distinguished for 32-bit arm
+	if p.Arch == "arm" && variant != "" {
+		armVariant := armVariantRe.FindStringSubmatch(strings.ToLower(variant))
+		if armVariant == nil {
+			return nil
+		}
+		p.Variant = "v" + armVariant[1]
+	}
+	return p
+}
+
+func getSubmatch(re *regexp.Regexp, match []string, name string) string {
+	if i := re.SubexpIndex(name); i >= 0 && i < len(match) {
+		return match[i]
+	}
+	return ""
+}
+
+func mergeAliases(aliases ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, a := range aliases {
+		for k, v := range a {
+			merged[strings.ToLower(k)] = v
+		}
+	}
+	return merged
+}
+
+type assetMatcher struct {
+	osAliases   map[string]string
+	archAliases map[string]string
+	patterns    []*regexp.Regexp
+}
+
+// newMatcher compiles the configured patterns. The default pattern is extended with the configured aliases.
+func (m *AssetMatching) newMatcher() (*assetMatcher, error) {
+	if m == nil {
+		return &assetMatcher{
+			osAliases:   defaultOSAliases,
+			archAliases: defaultArchAliases,
+			patterns:    []*regexp.Regexp{defaultAssetRe},
+		}, nil
+	}
+	am := &assetMatcher{
+		osAliases:   mergeAliases(defaultOSAliases, m.OSAliases),
+		archAliases: mergeAliases(defaultArchAliases, m.ArchAliases),
+	}
+	for _, pattern := range m.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset pattern %s: %w", pattern, err)
+		}
+		if re.SubexpIndex("os") < 0 || re.SubexpIndex("arch") < 0 {
+			return nil, fmt.Errorf("asset pattern %s must contain the named groups os and arch", pattern)
+		}
+		am.patterns = append(am.patterns, re)
+	}
+	if len(m.OSAliases) > 0 || len(m.ArchAliases) > 0 {
+		am.patterns = append(am.patterns, newAssetRegexp(am.osAliases, am.archAliases))
+	} else {
+		am.patterns = append(am.patterns, defaultAssetRe)
+	}
+	return am, nil
+}
+
+// match returns the platform of the asset or nil if the asset is not a plugin binary.
+func (am *assetMatcher) match(fileName string) *platform {
+	for _, re := range am.patterns {
+		match := re.FindStringSubmatch(fileName)
+		if match == nil {
+			continue
+		}
+		if p := am.normalizePlatform(getSubmatch(re, match, "os"), getSubmatch(re, match, "arch"), getSubmatch(re, match, "variant")); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// PlatformFallbacks maps a platform to the platforms whose assets can be served instead, in order of preference.
+type PlatformFallbacks map[string][]string
+
+// armFallbacks returns the fallbacks of arm platforms: lower GOARM variants and the assets without variant, or
+// all variants if no variant is requested.
+func armFallbacks(platformKey string) []string {
+	parts := strings.Split(platformKey, "/")
+	if len(parts) < 2 || parts[1] != "arm" {
+		return nil
+	}
+	osArm := parts[0] + "/arm"
+	if len(parts) == 2 {
+		// the GOARM version of the client is unknown, the variants that run on the most devices are preferred, but
+		// v7 (the default of Go) is served as the last resort instead of no asset at all
+		return []string{osArm + "/v6", osArm + "/v5", osArm + "/v7"}
+	}
+	fallbacks := make([]string, 0)
+	if armVariant := armVariantRe.FindStringSubmatch(parts[2]); armVariant != nil {
+		for v := armVariant[1][0] - 1; v >= '5'; v-- {
+			fallbacks = append(fallbacks, fmt.Sprintf("%s/v%c", osArm, v))
+		}
+	}
+	return append(fallbacks, osArm)
+}
+
+// Chain returns the platform followed by its fallbacks.
+func (f PlatformFallbacks) Chain(platformKey string) []string {
+	chain := []string{platformKey}
+	for _, fallback := range append(f[platformKey], armFallbacks(platformKey)...) {
+		if !containsString(chain, fallback) {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// FindAsset returns the asset of the first platform of the chain that is provided by the release and the platform
+// that is served. It returns nil if the release provides none of the platforms.
+func (f PlatformFallbacks) FindAsset(release *registry.PluginRelease, platformKey string) (*registry.PluginAsset, string) {
+	for _, p := range f.Chain(platformKey) {
+		if asset := release.Assets[p]; asset != nil {
+			return asset, p
+		}
+	}
+	return nil, ""
+}
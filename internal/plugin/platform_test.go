@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPlatform(t *testing.T) {
+	matcher, err := (*AssetMatching)(nil).newMatcher()
+	require.NoError(t, err)
+	testCases := []struct {
+		fileName string
+		expected string
+	}{
+		{"plugin_v1.0.0_linux_amd64", "linux/amd64"},
+		{"plugin_v1.0.0_windows_amd64.exe", "windows/amd64"},
+		{"plugin-1.0.0-linux-x86_64.tar.gz", "linux/amd64"},
+		{"plugin_1.0.0_macos_aarch64.zip", "darwin/arm64"},
+		{"plugin_1.0.0_Darwin_x86_64", "darwin/amd64"},
+		{"plugin_1.0.0_linux_i686", "linux/386"},
+		{"plugin_1.0.0_linux_arm", "linux/arm"},
+		{"plugin_1.0.0_linux_armv7", "linux/arm/v7"},
+		{"plugin_1.0.0_linux_arm_6", "linux/arm/v6"},
+		{"plugin_1.0.0_linux_arm64", "linux/arm64"},
+		{"plugin_1.0.0_linux_armhf.tgz", "linux/arm/v7"},
+		{"plugin_1.0.0_linux_ppc64el", "linux/ppc64le"},
+		{"plugin_1.0.0_darwin_all", "darwin/all"},
+		{"plugin_1.0.0_darwin_universal.tar.gz", "darwin/all"},
+		{"plugin_1.0.0_linux_amd64.sbom", ""},
+		{"plugin_1.0.0_emac_amd64", ""},
+		{"checksums.txt", ""},
+	}
+	for _, tc := range testCases {
+		p := matcher.match(tc.fileName)
+		if tc.expected == "" {
+			require.Nil(t, p, tc.fileName)
+			continue
+		}
+		require.NotNil(t, p, tc.fileName)
+		require.Equal(t, tc.expected, p.String(), tc.fileName)
+	}
+}
+
+func TestMatchPlatformWithOverrides(t *testing.T) {
+	matching := &AssetMatching{
+		Patterns:    []string{`^plugin-(?P<arch>[a-z0-9]+)-(?P<os>[a-z]+)$`},
+		OSAliases:   map[string]string{"apple-darwin": "darwin"},
+		ArchAliases: map[string]string{"armv7l": "arm/v7", "universal": "amd64"},
+	}
+	matcher, err := matching.newMatcher()
+	require.NoError(t, err)
+	require.Equal(t, "linux/arm/v7", matcher.match("plugin-armv7l-linux").String())
+	require.Equal(t, "darwin/amd64", matcher.match("plugin_apple-darwin_universal").String())
+	require.Equal(t, "linux/amd64", matcher.match("plugin_v1.0.0_linux_amd64").String())
+	require.Nil(t, matcher.match("plugin-sparc-unknown"))
+
+	_, err = (&AssetMatching{Patterns: []string{`(?P<os>[a-z]+)`}}).newMatcher()
+	require.ErrorContains(t, err, "must contain the named groups os and arch")
+	_, err = (&AssetMatching{Patterns: []string{`(`}}).newMatcher()
+	require.ErrorContains(t, err, "invalid asset pattern")
+}
+
+func TestGetPluginAssetsArmVariants(t *testing.T) {
+	assets, err := getPluginAssets(context.Background(), newTestGitHubRelease(
+		&github.ReleaseAsset{Name: github.String("plugin_linux_arm")},
+		&github.ReleaseAsset{Name: github.String("plugin_linux_armv6")},
+		&github.ReleaseAsset{Name: github.String("plugin_linux_armv7")},
+	), nil)
+	require.NoError(t, err)
+	require.Len(t, assets, 3)
+	require.Empty(t, assets["linux/arm"].Variant)
+	require.Equal(t, "v6", assets["linux/arm/v6"].Variant)
+	require.Equal(t, "arm", assets["linux/arm/v7"].Arch)
+	require.Equal(t, "v7", assets["linux/arm/v7"].Variant)
+}
+
+func TestPlatformFallbacks(t *testing.T) {
+	fallbacks := PlatformFallbacks{
+		"darwin/arm64": {"darwin/all", "darwin/amd64"},
+	}
+	require.Equal(t, []string{"darwin/arm64", "darwin/all", "darwin/amd64"}, fallbacks.Chain("darwin/arm64"))
+	require.Equal(t, []string{"linux/amd64"}, fallbacks.Chain("linux/amd64"))
+	require.Equal(t, []string{"linux/arm/v7", "linux/arm/v6", "linux/arm/v5", "linux/arm"}, fallbacks.Chain("linux/arm/v7"))
+	require.Equal(t, []string{"linux/arm", "linux/arm/v6", "linux/arm/v5", "linux/arm/v7"}, fallbacks.Chain("linux/arm"))
+
+	release := &registry.PluginRelease{Assets: map[string]*registry.PluginAsset{
+		"darwin/amd64": {FileName: "plugin_darwin_amd64"},
+		"linux/arm/v6": {FileName: "plugin_linux_armv6"},
+	}}
+	asset, platform := fallbacks.FindAsset(release, "darwin/arm64")
+	require.Equal(t, "plugin_darwin_amd64", asset.FileName)
+	require.Equal(t, "darwin/amd64", platform)
+	asset, platform = fallbacks.FindAsset(release, "linux/arm/v7")
+	require.Equal(t, "plugin_linux_armv6", asset.FileName)
+	require.Equal(t, "linux/arm/v6", platform)
+	asset, platform = fallbacks.FindAsset(release, "linux/arm/v5")
+	require.Nil(t, asset)
+	require.Empty(t, platform)
+
+	release.Assets["darwin/all"] = &registry.PluginAsset{FileName: "plugin_darwin_all"}
+	asset, _ = fallbacks.FindAsset(release, "darwin/arm64")
+	require.Equal(t, "plugin_darwin_all", asset.FileName)
+}
@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -10,14 +11,31 @@ import (
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/google/go-github/v59/github"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Plugin struct {
-	Type        string
-	Name        string
-	Aliases     []string
-	Repo        string
-	Description string
+	Type         string
+	Name         string
+	Aliases      []string
+	Repo         string
+	Description  string
+	Verification *Verification
+	// AssetMatching is optional and overrides how release assets are mapped to platforms.
+	AssetMatching *AssetMatching
+	// License, Homepage, Maintainers and Keywords are optional and override the metadata
+	// of the GitHub repository (license, homepage, owner and topics).
+	License     string
+	Homepage    string
+	Maintainers []string
+	Keywords    []string
+	// MinSemanticReleaseVersion is optional and is only maintained in the catalog.
+	MinSemanticReleaseVersion string
+	// Compatibility is optional and overrides the semantic-release constraints, required and conflicting plugins
+	// that are declared by the plugin-metadata.json assets of the releases. The first matching entry is used.
+	Compatibility []*Compatibility
 }
 
 var CollectionPrefix = "dev"
@@ -30,6 +48,8 @@ type fsPluginData struct {
 	Versions      *struct{} `firestore:",omitempty"`
 	UpdatedAt     *struct{} `firestore:",omitempty"`
 	Description   *struct{} `firestore:",omitempty"`
+	// MinSemanticReleaseVersion is only maintained in the catalog
+	MinSemanticReleaseVersion *struct{} `firestore:",omitempty"`
 }
 
 type fsPluginReleaseData struct {
@@ -67,34 +87,102 @@ func (p *Plugin) savePluginRelease(ctx context.Context, db *firestore.Client, pr
 	return err
 }
 
-func (p *Plugin) updateReleaseFromGitHub(ctx context.Context, db *firestore.Client, ghClient *github.Client, version string) error {
+// verificationRequired reports whether releases that do not pass the verification must not be served.
+func (p *Plugin) verificationRequired() bool {
+	return p.Verification != nil && p.Verification.Required
+}
+
+// deletePluginRelease removes a stored release that has been refused, so that it can not be resolved anymore.
+func (p *Plugin) deletePluginRelease(ctx context.Context, db *firestore.Client, version string) error {
+	_, err := p.getVersionDocRef(db, version).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	return p.touchPlugin(ctx, db)
+}
+
+// touchPlugin updates the plugin document after its versions have changed, so that its update time, which is
+// served as Last-Modified of the plugin, covers the versions. Plugins that have not been stored yet are skipped.
+func (p *Plugin) touchPlugin(ctx context.Context, db *firestore.Client) error {
+	_, err := p.getDocRef(db).Update(ctx, []firestore.Update{{Path: "VersionsUpdatedAt", Value: firestore.ServerTimestamp}})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+func (p *Plugin) updateReleaseFromGitHub(ctx context.Context, log *logrus.Entry, db *firestore.Client, ghClient *github.Client, version string) error {
 	release, err := getGitHubRelease(ctx, ghClient, p.Repo, fmt.Sprintf("v%s", version))
 	if err != nil {
 		return err
 	}
-	pr, err := toPluginRelease(ctx, release)
+	// a missing release is not an error, the release is ingested for the first time
+	previous, _ := p.GetRelease(ctx, db, semver.MustParse(release.GetTagName()).String())
+	pr, err := p.toPluginRelease(ctx, log, release, previous)
+	vErr := &VerificationError{}
+	if errors.As(err, &vErr) && previous != nil && p.verificationRequired() {
+		if dErr := p.deletePluginRelease(ctx, db, vErr.Version); dErr != nil {
+			return dErr
+		}
+	}
 	if err != nil {
 		return err
 	}
 	return p.savePluginRelease(ctx, db, pr)
 }
 
-func (p *Plugin) updateAllReleasesFromGitHub(ctx context.Context, db *firestore.Client, ghClient *github.Client) error {
+func (p *Plugin) getStoredReleases(ctx context.Context, db *firestore.Client) (map[string]*registry.PluginRelease, error) {
+	docs, err := p.getVersionsColRef(db).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]*registry.PluginRelease, len(docs))
+	for _, doc := range docs {
+		var pr registry.PluginRelease
+		if dErr := doc.DataTo(&pr); dErr != nil {
+			return nil, dErr
+		}
+		pr.UpdatedAt = doc.UpdateTime
+		p.applyCompatibility(&pr)
+		ret[doc.Ref.ID] = &pr
+	}
+	return ret, nil
+}
+
+// updateAllReleasesFromGitHub saves all releases of the plugin and returns the versions of the releases that
+// have been refused, because they did not pass the verification. If the verification is required, refused releases
+// that have been stored before, e.g. before the verification has been required, are deleted.
+func (p *Plugin) updateAllReleasesFromGitHub(ctx context.Context, log *logrus.Entry, db *firestore.Client, ghClient *github.Client) (map[string]bool, error) {
 	releases, err := getAllGitHubReleases(ctx, ghClient, p.Repo)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	storedReleases, err := p.getStoredReleases(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	refused := make(map[string]bool)
 	for _, release := range releases {
-		pr, err := toPluginRelease(ctx, release)
+		pr, err := p.toPluginRelease(ctx, log, release, storedReleases[semver.MustParse(release.GetTagName()).String()])
+		vErr := &VerificationError{}
+		if errors.As(err, &vErr) {
+			refused[vErr.Version] = true
+			if storedReleases[vErr.Version] != nil && p.verificationRequired() {
+				if dErr := p.deletePluginRelease(ctx, db, vErr.Version); dErr != nil {
+					return nil, dErr
+				}
+			}
+			continue
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		err = p.savePluginRelease(ctx, db, pr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	return nil
+	return refused, nil
 }
 
 func (p *Plugin) getLatestReleaseFromGitHub(ctx context.Context, ghClient *github.Client) (string, error) {
@@ -116,8 +204,8 @@ func (p *Plugin) getLatestReleaseFromGitHub(ctx context.Context, ghClient *githu
 	return lrVersion.String(), nil
 }
 
-func (p *Plugin) toPlugin() *fsPluginData {
-	return &fsPluginData{
+func (p *Plugin) toPlugin(metadata *repositoryMetadata) *fsPluginData {
+	plugin := &fsPluginData{
 		Plugin: &registry.Plugin{
 			FullName: p.GetFullName(),
 			Type:     p.Type,
@@ -125,20 +213,88 @@ func (p *Plugin) toPlugin() *fsPluginData {
 			URL:      fmt.Sprintf("https://github.com/%s", p.Repo),
 		},
 	}
+	if metadata != nil {
+		plugin.Plugin.License = metadata.License
+		plugin.Plugin.Homepage = metadata.Homepage
+		plugin.Plugin.Maintainers = metadata.Maintainers
+		plugin.Plugin.Keywords = metadata.Keywords
+	}
+	return plugin
+}
+
+// getStoredRepositoryMetadata returns the repository metadata of the stored plugin or nil if the plugin has not been
+// stored yet.
+func (p *Plugin) getStoredRepositoryMetadata(ctx context.Context, db *firestore.Client) (*repositoryMetadata, error) {
+	res, err := p.getDocRef(db).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pluginData := fsPluginData{Plugin: &registry.Plugin{}}
+	if dErr := res.DataTo(&pluginData); dErr != nil {
+		return nil, dErr
+	}
+	return &repositoryMetadata{
+		License:     pluginData.License,
+		Homepage:    pluginData.Homepage,
+		Maintainers: pluginData.Maintainers,
+		Keywords:    pluginData.Keywords,
+	}, nil
+}
+
+// applyCatalogMetadata sets the static description and overrides the stored metadata with the values of the catalog.
+func (p *Plugin) applyCatalogMetadata(rp *registry.Plugin) {
+	rp.Description = p.Description
+	rp.MinSemanticReleaseVersion = p.MinSemanticReleaseVersion
+	if p.License != "" {
+		rp.License = p.License
+	}
+	if p.Homepage != "" {
+		rp.Homepage = p.Homepage
+	}
+	if len(p.Maintainers) > 0 {
+		rp.Maintainers = p.Maintainers
+	}
+	if len(p.Keywords) > 0 {
+		rp.Keywords = p.Keywords
+	}
 }
 
-func (p *Plugin) Update(ctx context.Context, db *firestore.Client, ghClient *github.Client, version string) error {
+func (p *Plugin) Update(ctx context.Context, log *logrus.Entry, db *firestore.Client, ghClient *github.Client, version string) error {
 	latestRelease, err := p.getLatestReleaseFromGitHub(ctx, ghClient)
 	if err != nil {
 		return err
 	}
+	// the metadata is optional, the releases are still ingested if it can not be fetched
+	metadata, err := getRepositoryMetadata(ctx, ghClient, p.Repo)
+	if err != nil {
+		log.Warnf("could not get the repository metadata of %s, keeping the stored metadata: %v", p.GetFullName(), err)
+		metadata, err = p.getStoredRepositoryMetadata(ctx, db)
+		if err != nil {
+			return err
+		}
+	}
 
 	updateMain := true
+	var refused map[string]bool
 	if version == "" {
-		err = p.updateAllReleasesFromGitHub(ctx, db, ghClient)
+		refused, err = p.updateAllReleasesFromGitHub(ctx, log, db, ghClient)
+		// keep the previous latest release if the new one has been refused
+		updateMain = !refused[latestRelease]
 	} else {
-		err = p.updateReleaseFromGitHub(ctx, db, ghClient, version)
+		err = p.updateReleaseFromGitHub(ctx, log, db, ghClient, version)
 		updateMain = version == latestRelease
+		if vErr := (&VerificationError{}); errors.As(err, &vErr) {
+			refused = map[string]bool{vErr.Version: true}
+		}
+	}
+	// the latest release only has to be replaced if it has been deleted
+	if len(refused) > 0 && p.verificationRequired() {
+		if rErr := p.replaceRefusedLatestRelease(ctx, db, refused); rErr != nil {
+			return rErr
+		}
 	}
 	if err != nil {
 		return err
@@ -146,15 +302,49 @@ func (p *Plugin) Update(ctx context.Context, db *firestore.Client, ghClient *git
 
 	// do not update main entry if latest release has not been added to database
 	if !updateMain {
-		return nil
+		return p.touchPlugin(ctx, db)
 	}
 
-	plugin := p.toPlugin()
+	plugin := p.toPlugin(metadata)
 	plugin.LatestReleaseRef = p.getVersionDocRef(db, latestRelease)
 	_, err = p.getDocRef(db).Set(ctx, plugin)
 	return err
 }
 
+// replaceRefusedLatestRelease points the plugin to its newest stored stable release if the previous latest
+// release has been refused and deleted.
+func (p *Plugin) replaceRefusedLatestRelease(ctx context.Context, db *firestore.Client, refused map[string]bool) error {
+	res, err := p.getDocRef(db).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pluginData := fsPluginData{Plugin: &registry.Plugin{}}
+	if dErr := res.DataTo(&pluginData); dErr != nil {
+		return dErr
+	}
+	if pluginData.LatestReleaseRef == nil || !refused[pluginData.LatestReleaseRef.ID] {
+		return nil
+	}
+	versions, err := p.GetVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+	// constraints without a prerelease do not match prereleases
+	constraint, err := semver.NewConstraint(">= 0.0.0")
+	if err != nil {
+		return err
+	}
+	latestRelease, err := findMatchingVersion(versions, constraint)
+	if err != nil {
+		return fmt.Errorf("plugin has no accepted release: %w", err)
+	}
+	_, err = p.getDocRef(db).Update(ctx, []firestore.Update{{Path: "LatestReleaseRef", Value: p.getVersionDocRef(db, latestRelease)}})
+	return err
+}
+
 func (p *Plugin) GetVersions(ctx context.Context, db *firestore.Client) ([]string, error) {
 	versionRefs, err := p.getVersionsColRef(db).DocumentRefs(ctx).GetAll()
 	if err != nil {
@@ -177,8 +367,8 @@ func (p *Plugin) getPlugin(ctx context.Context, db *firestore.Client) (*registry
 		return nil, dErr
 	}
 	pluginData.Plugin.UpdatedAt = res.UpdateTime
-	// description is a static value that is not stored in firestore
-	pluginData.Plugin.Description = p.Description
+	// description and catalog metadata are static values that are not stored in firestore
+	p.applyCatalogMetadata(pluginData.Plugin)
 
 	// resolve latest release
 	res, err = pluginData.LatestReleaseRef.Get(ctx)
@@ -190,10 +380,26 @@ func (p *Plugin) getPlugin(ctx context.Context, db *firestore.Client) (*registry
 		return nil, dErr
 	}
 	latestPluginRelease.UpdatedAt = res.UpdateTime
+	p.applyCompatibility(&latestPluginRelease)
 	pluginData.Plugin.LatestRelease = &latestPluginRelease
 	return pluginData.Plugin, nil
 }
 
+// GetSummary returns the plugin with its latest release but without the list of versions.
+// Plugins that have not been ingested yet are returned without latest release.
+func (p *Plugin) GetSummary(ctx context.Context, db *firestore.Client) (*registry.Plugin, error) {
+	summary, err := p.getPlugin(ctx, db)
+	if status.Code(err) == codes.NotFound {
+		summary = p.toPlugin(nil).Plugin
+		p.applyCatalogMetadata(summary)
+		return summary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin: %w", err)
+	}
+	return summary, nil
+}
+
 func (p *Plugin) Get(ctx context.Context, db *firestore.Client) (*registry.Plugin, error) {
 	latestRelease, err := p.getPlugin(ctx, db)
 	if err != nil {
@@ -225,18 +431,31 @@ func findMatchingVersion(stringVersions []string, constraint *semver.Constraints
 	return "", fmt.Errorf("no matching version found for constraint %s", constraint.String())
 }
 
-func (p *Plugin) GetReleaseWithVersionConstraint(ctx context.Context, db *firestore.Client, versionConstraint string) (*registry.PluginRelease, error) {
+// GetReleaseWithVersionConstraint returns the newest release that matches the version constraint. If the version of
+// semantic-release is set, only releases that are compatible with it are considered.
+func (p *Plugin) GetReleaseWithVersionConstraint(ctx context.Context, db *firestore.Client, versionConstraint string, semRelVersion *semver.Version) (*registry.PluginRelease, error) {
 	if versionConstraint == "latest" {
 		latestPlugin, err := p.getPlugin(ctx, db)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get latest release: %w", err)
 		}
-		return latestPlugin.LatestRelease, nil
+		if latestPlugin.LatestRelease.IsCompatible(semRelVersion) {
+			return latestPlugin.LatestRelease, nil
+		}
+		// fall back to the newest compatible stable release
+		constraint, err := semver.NewConstraint("<= " + latestPlugin.LatestRelease.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse latest version: %w", err)
+		}
+		return p.findCompatibleRelease(ctx, db, constraint, semRelVersion)
 	}
 	constraint, err := semver.NewConstraint(versionConstraint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse version constraint: %w", err)
 	}
+	if semRelVersion != nil {
+		return p.findCompatibleRelease(ctx, db, constraint, semRelVersion)
+	}
 
 	versions, err := p.GetVersions(ctx, db)
 	if err != nil {
@@ -260,9 +479,55 @@ func (p *Plugin) GetRelease(ctx context.Context, db *firestore.Client, version s
 		return nil, dErr
 	}
 	pr.UpdatedAt = pluginRelease.UpdateTime
+	p.applyCompatibility(&pr)
 	return &pr, nil
 }
 
+// GetPlatforms returns the platforms that are supported by the stored releases of the plugin.
+func (p *Plugin) GetPlatforms(ctx context.Context, db *firestore.Client) (*registry.PluginPlatforms, error) {
+	latestPlugin, err := p.getPlugin(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin: %w", err)
+	}
+	storedReleases, err := p.getStoredReleases(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases: %w", err)
+	}
+	releases := make([]*registry.PluginRelease, 0, len(storedReleases))
+	for _, release := range storedReleases {
+		releases = append(releases, release)
+	}
+	latestVersion := ""
+	if latestPlugin.LatestRelease != nil {
+		latestVersion = latestPlugin.LatestRelease.Version
+	}
+	return registry.NewPluginPlatforms(p.GetFullName(), latestVersion, releases), nil
+}
+
+// GetReleaseNotes returns the release notes of the stored releases after from up to and including to.
+// If to is nil, the latest release is used.
+func (p *Plugin) GetReleaseNotes(ctx context.Context, db *firestore.Client, from, to *semver.Version) (*registry.ReleaseNotesRange, error) {
+	if to == nil {
+		latestPlugin, err := p.getPlugin(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get plugin: %w", err)
+		}
+		to, err = semver.NewVersion(latestPlugin.LatestRelease.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse latest version: %w", err)
+		}
+	}
+	storedReleases, err := p.getStoredReleases(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases: %w", err)
+	}
+	releases := make([]*registry.PluginRelease, 0, len(storedReleases))
+	for _, release := range storedReleases {
+		releases = append(releases, release)
+	}
+	return registry.NewReleaseNotesRange(p.GetFullName(), from, to, releases), nil
+}
+
 type Plugins []*Plugin
 
 func (l Plugins) Find(name string) *Plugin {
@@ -280,3 +545,34 @@ func (l Plugins) Find(name string) *Plugin {
 	}
 	return nil
 }
+
+// Matches reports whether the plugin has the given type (if set) and the query (if set) is contained in its
+// full name, aliases, keywords or description. The comparison is case-insensitive.
+func (p *Plugin) Matches(pluginType, query string) bool {
+	if pluginType != "" && !strings.EqualFold(p.Type, pluginType) {
+		return false
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+	candidates := append([]string{p.GetFullName(), p.Description}, p.GetAliases()...)
+	candidates = append(candidates, p.Keywords...)
+	for _, c := range candidates {
+		if strings.Contains(strings.ToLower(c), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the plugins that match the type and the query.
+func (l Plugins) Filter(pluginType, query string) Plugins {
+	ret := make(Plugins, 0, len(l))
+	for _, p := range l {
+		if p.Matches(pluginType, query) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
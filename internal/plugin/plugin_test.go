@@ -32,3 +32,54 @@ func TestFindMatchingVersion(t *testing.T) {
 	_, err = findMatchingVersion([]string{"1.0.0", "1.1.0", "1.2.0"}, constraint)
 	require.ErrorContains(t, err, "no matching version found")
 }
+
+func TestPluginsFilter(t *testing.T) {
+	plugins := Plugins{
+		{Type: "provider", Name: "github", Description: "Publishes releases on GitHub."},
+		{Type: "provider", Name: "gitlab", Description: "Publishes releases on GitLab."},
+		{Type: "commit-analyzer", Name: "cz", Aliases: []string{"default"}, Description: "Conventional Commits"},
+	}
+	names := func(l Plugins) []string {
+		ret := make([]string, len(l))
+		for i, p := range l {
+			ret[i] = p.GetFullName()
+		}
+		return ret
+	}
+	require.Len(t, plugins.Filter("", ""), 3)
+	require.Equal(t, []string{"provider-github", "provider-gitlab"}, names(plugins.Filter("Provider", "")))
+	require.Equal(t, []string{"provider-gitlab"}, names(plugins.Filter("", "gitlab")))
+	require.Equal(t, []string{"commit-analyzer-cz"}, names(plugins.Filter("", "conventional")))
+	require.Equal(t, []string{"commit-analyzer-cz"}, names(plugins.Filter("commit-analyzer", "default")))
+	require.Empty(t, plugins.Filter("condition", "git"))
+}
+
+func TestApplyCatalogMetadata(t *testing.T) {
+	p := &Plugin{
+		Type:                      "provider",
+		Name:                      "git",
+		Repo:                      "go-semantic-release/provider-git",
+		Description:               "A provider plugin.",
+		Keywords:                  []string{"git"},
+		MinSemanticReleaseVersion: "2.0.0",
+	}
+	rp := p.toPlugin(&repositoryMetadata{
+		License:     "MIT",
+		Homepage:    "https://example.com",
+		Maintainers: []string{"octocat"},
+		Keywords:    []string{"semantic-release"},
+	}).Plugin
+	p.applyCatalogMetadata(rp)
+	require.Equal(t, "A provider plugin.", rp.Description)
+	require.Equal(t, "MIT", rp.License)
+	require.Equal(t, "https://example.com", rp.Homepage)
+	require.Equal(t, []string{"octocat"}, rp.Maintainers)
+	require.Equal(t, []string{"git"}, rp.Keywords)
+	require.Equal(t, "2.0.0", rp.MinSemanticReleaseVersion)
+
+	p.License = "Apache-2.0"
+	p.Maintainers = []string{"christophwitzko"}
+	p.applyCatalogMetadata(rp)
+	require.Equal(t, "Apache-2.0", rp.License)
+	require.Equal(t, []string{"christophwitzko"}, rp.Maintainers)
+}
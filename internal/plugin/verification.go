@@ -0,0 +1,434 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+)
+
+var (
+	errMissingChecksumFile  = errors.New("release has no checksum file")
+	errUnsignedRelease      = errors.New("release is not signed")
+	errMissingProvenance    = errors.New("release has no provenance attestation")
+	errUnverifiedProvenance = errors.New("provenance attestation is not signed by a configured key")
+)
+
+// Verification configures how the upstream releases of a plugin are verified during ingestion.
+// The signature of the checksum file is verified with the configured keys: minisign signatures are read from
+// <checksum file>.minisig, cosign (base64 encoded) and binary GPG signatures from <checksum file>.sig and
+// armored GPG signatures from <checksum file>.asc.
+type Verification struct {
+	// Required refuses releases without a valid signature (and provenance, if enabled).
+	Required bool
+	// MinisignPublicKeys are minisign public keys, e.g. RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3.
+	MinisignPublicKeys []string
+	// CosignPublicKeys are PEM encoded ECDSA or ed25519 public keys (cosign.pub).
+	CosignPublicKeys []string
+	// GPGPublicKeys are armored GPG public keys.
+	GPGPublicKeys []string
+	// Provenance enables the verification of SLSA provenance attestations (*.intoto.jsonl). The attestation
+	// envelopes are verified with the CosignPublicKeys. Attestations that are signed with a Sigstore certificate
+	// are recorded as unverified, because the certificate chain is not verified, and do not satisfy Required.
+	Provenance bool
+}
+
+// VerificationError is returned if a release does not pass the verification, e.g. because its signature or
+// checksums do not match. Errors that prevented the verification, e.g. failed downloads, are not VerificationErrors.
+type VerificationError struct {
+	Version string
+	Err     error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification of release %s failed: %s", e.Version, e.Err.Error())
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// verificationFailed marks the error as a failed verification, the version is set by releaseVerificationError.
+func verificationFailed(err error) error {
+	return &VerificationError{Err: err}
+}
+
+// releaseVerificationError sets the version of a failed verification, other errors are wrapped.
+func releaseVerificationError(version string, err error) error {
+	if vErr := (&VerificationError{}); errors.As(err, &vErr) {
+		vErr.Version = version
+		return vErr
+	}
+	return fmt.Errorf("failed to verify release %s: %w", version, err)
+}
+
+func findAsset(gha []*github.ReleaseAsset, name string) *github.ReleaseAsset {
+	for _, asset := range gha {
+		if strings.EqualFold(asset.GetName(), name) {
+			return asset
+		}
+	}
+	return nil
+}
+
+func (v *Verification) verifyRelease(ctx context.Context, fullRepo string, gha []*github.ReleaseAsset, assets map[string]*registry.PluginAsset) (*registry.ReleaseVerification, error) {
+	result := &registry.ReleaseVerification{VerifiedAt: time.Now().UTC()}
+	var checksumAsset *github.ReleaseAsset
+	for _, asset := range gha {
+		if isChecksumFile(asset) {
+			checksumAsset = asset
+			break
+		}
+	}
+	if checksumAsset == nil {
+		if v.Required {
+			return nil, verificationFailed(errMissingChecksumFile)
+		}
+		return result, nil
+	}
+
+	// the checksum file is fetched again, so the verified content must match the checksums of the assets
+	checksumFile, err := fetchAsset(ctx, checksumAsset.GetBrowserDownloadURL())
+	if err != nil {
+		return nil, err
+	}
+	checksums := parseChecksumFile(checksumFile)
+	for _, pa := range assets {
+		c := checksums[strings.ToLower(pa.FileName)]
+		if c == nil {
+			// the checksum of the asset is not covered by the signature
+			if v.Required {
+				return nil, verificationFailed(fmt.Errorf("asset %s is not listed in the checksum file", pa.FileName))
+			}
+			continue
+		}
+		if c.Checksum != pa.Checksum {
+			return nil, verificationFailed(fmt.Errorf("checksum of %s changed during verification", pa.FileName))
+		}
+	}
+
+	signatureType, err := v.verifyChecksumFileSignature(ctx, gha, checksumAsset.GetName(), checksumFile)
+	if err != nil {
+		return nil, err
+	}
+	if signatureType == "" && v.Required {
+		return nil, verificationFailed(errUnsignedRelease)
+	}
+	result.SignatureVerified = signatureType != ""
+	result.SignatureType = signatureType
+	return result, nil
+}
+
+// verifyReleaseProvenance records the provenance of the release in the result. The attestation covers the digests
+// of all assets, so it must be verified after the missing checksums have been computed.
+func (v *Verification) verifyReleaseProvenance(ctx context.Context, fullRepo string, gha []*github.ReleaseAsset, assets map[string]*registry.PluginAsset, result *registry.ReleaseVerification) error {
+	if !v.Provenance {
+		return nil
+	}
+	builderID, provenanceStatus, err := verifyProvenance(ctx, fullRepo, v.CosignPublicKeys, gha, assets)
+	if err != nil {
+		return err
+	}
+	if v.Required && provenanceStatus == "" {
+		return verificationFailed(errMissingProvenance)
+	}
+	if v.Required && provenanceStatus != registry.ProvenanceStatusVerified {
+		return verificationFailed(errUnverifiedProvenance)
+	}
+	result.ProvenanceVerified = provenanceStatus == registry.ProvenanceStatusVerified
+	result.ProvenanceStatus = provenanceStatus
+	result.ProvenanceBuilderID = builderID
+	return nil
+}
+
+// verifyChecksumFileSignature returns the type of the verified signature or an empty string if the checksum
+// file is not signed. An existing signature that can not be verified with the configured keys is an error.
+func (v *Verification) verifyChecksumFileSignature(ctx context.Context, gha []*github.ReleaseAsset, checksumFileName string, checksumFile []byte) (registry.SignatureType, error) {
+	if asset := findAsset(gha, checksumFileName+".minisig"); asset != nil && len(v.MinisignPublicKeys) > 0 {
+		sig, err := fetchAsset(ctx, asset.GetBrowserDownloadURL())
+		if err != nil {
+			return "", err
+		}
+		if err := verifyMinisign(v.MinisignPublicKeys, checksumFile, sig); err != nil {
+			return "", err
+		}
+		return registry.SignatureTypeMinisign, nil
+	}
+	if asset := findAsset(gha, checksumFileName+".sig"); asset != nil && (len(v.CosignPublicKeys) > 0 || len(v.GPGPublicKeys) > 0) {
+		sig, err := fetchAsset(ctx, asset.GetBrowserDownloadURL())
+		if err != nil {
+			return "", err
+		}
+		cosignErr := verifyCosign(v.CosignPublicKeys, checksumFile, sig)
+		if cosignErr == nil {
+			return registry.SignatureTypeCosign, nil
+		}
+		gpgErr := verifyGPG(v.GPGPublicKeys, checksumFile, sig, false)
+		if gpgErr == nil {
+			return registry.SignatureTypeGPG, nil
+		}
+		return "", errors.Join(cosignErr, gpgErr)
+	}
+	if asset := findAsset(gha, checksumFileName+".asc"); asset != nil && len(v.GPGPublicKeys) > 0 {
+		sig, err := fetchAsset(ctx, asset.GetBrowserDownloadURL())
+		if err != nil {
+			return "", err
+		}
+		if err := verifyGPG(v.GPGPublicKeys, checksumFile, sig, true); err != nil {
+			return "", err
+		}
+		return registry.SignatureTypeGPG, nil
+	}
+	return "", nil
+}
+
+func verifyMinisign(publicKeys []string, message, sig []byte) error {
+	for _, k := range publicKeys {
+		var publicKey minisign.PublicKey
+		if err := publicKey.UnmarshalText([]byte(k)); err != nil {
+			return fmt.Errorf("invalid minisign public key: %w", err)
+		}
+		// minisign.Verify supports both legacy and pre-hashed signatures
+		if minisign.Verify(publicKey, message, sig) {
+			return nil
+		}
+	}
+	return verificationFailed(errors.New("invalid minisign signature"))
+}
+
+func verifyCosign(publicKeys []string, message, sig []byte) error {
+	if len(publicKeys) == 0 {
+		return errors.New("no cosign public keys configured")
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return verificationFailed(fmt.Errorf("failed to decode cosign signature: %w", err))
+	}
+	digest := sha256.Sum256(message)
+	for _, k := range publicKeys {
+		block, _ := pem.Decode([]byte(k))
+		if block == nil {
+			return errors.New("invalid cosign public key: no PEM block found")
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("invalid cosign public key: %w", err)
+		}
+		switch pk := publicKey.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(pk, digest[:], rawSig) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(pk, message, rawSig) {
+				return nil
+			}
+		default:
+			return fmt.Errorf("unsupported cosign public key type %T", publicKey)
+		}
+	}
+	return verificationFailed(errors.New("invalid cosign signature"))
+}
+
+func verifyGPG(publicKeys []string, message, sig []byte, armored bool) error {
+	if len(publicKeys) == 0 {
+		return errors.New("no GPG public keys configured")
+	}
+	var keyRing openpgp.EntityList
+	for _, k := range publicKeys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(k))
+		if err != nil {
+			return fmt.Errorf("invalid GPG public key: %w", err)
+		}
+		keyRing = append(keyRing, entities...)
+	}
+	var err error
+	if armored {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, bytes.NewReader(message), bytes.NewReader(sig), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyRing, bytes.NewReader(message), bytes.NewReader(sig), nil)
+	}
+	if err != nil {
+		return verificationFailed(fmt.Errorf("invalid GPG signature: %w", err))
+	}
+	return nil
+}
+
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// verify reports whether the envelope has a valid signature of one of the cosign public keys. DSSE signatures
+// cover the pre-authentication encoding of the payload type and the payload.
+func (e *dsseEnvelope) verify(publicKeys []string, payload []byte) bool {
+	if len(publicKeys) == 0 {
+		return false
+	}
+	message := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(e.PayloadType), e.PayloadType, len(payload), payload))
+	for _, sig := range e.Signatures {
+		if verifyCosign(publicKeys, message, []byte(sig.Sig)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+type provenanceStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate struct {
+		// SLSA provenance v0.2
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Invocation struct {
+			ConfigSource struct {
+				URI string `json:"uri"`
+			} `json:"configSource"`
+		} `json:"invocation"`
+		// SLSA provenance v1
+		BuildDefinition struct {
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+	// signed is set if the envelope of the statement has been verified.
+	signed bool
+}
+
+func (s *provenanceStatement) builderID() string {
+	if s.Predicate.RunDetails.Builder.ID != "" {
+		return s.Predicate.RunDetails.Builder.ID
+	}
+	return s.Predicate.Builder.ID
+}
+
+func (s *provenanceStatement) sourceURI() string {
+	if s.Predicate.BuildDefinition.ExternalParameters.Workflow.Repository != "" {
+		return s.Predicate.BuildDefinition.ExternalParameters.Workflow.Repository
+	}
+	return s.Predicate.Invocation.ConfigSource.URI
+}
+
+// normalizeSourceURI returns the repository of a provenance source URI, e.g. github.com/owner/repo
+// for git+https://github.com/owner/repo@refs/tags/v1.0.0.
+func normalizeSourceURI(uri string) string {
+	uri = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(uri), "git+"))
+	if _, rest, ok := strings.Cut(uri, "://"); ok {
+		uri = rest
+	}
+	uri, _, _ = strings.Cut(uri, "@")
+	uri, _, _ = strings.Cut(uri, "#")
+	uri, _, _ = strings.Cut(uri, "?")
+	return strings.TrimSuffix(strings.TrimSuffix(uri, "/"), ".git")
+}
+
+func parseProvenance(content []byte, publicKeys []string) ([]*provenanceStatement, error) {
+	statements := make([]*provenanceStatement, 0)
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var envelope dsseEnvelope
+		if err := json.Unmarshal(line, &envelope); err != nil {
+			return nil, verificationFailed(fmt.Errorf("failed to decode attestation: %w", err))
+		}
+		if envelope.PayloadType != "application/vnd.in-toto+json" {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			return nil, verificationFailed(fmt.Errorf("failed to decode attestation payload: %w", err))
+		}
+		var statement provenanceStatement
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			return nil, verificationFailed(fmt.Errorf("failed to decode attestation statement: %w", err))
+		}
+		if !strings.HasPrefix(statement.PredicateType, "https://slsa.dev/provenance/") {
+			continue
+		}
+		statement.signed = envelope.verify(publicKeys, payload)
+		statements = append(statements, &statement)
+	}
+	return statements, nil
+}
+
+// verifyProvenance checks that the SLSA provenance attestations of the release have been created for the
+// plugin repository and that their subjects cover all plugin assets with matching digests. It returns the
+// builder ID and the status of the attestations, which is empty if the release has no attestation. The status is
+// only verified if all DSSE envelopes are signed by one of the public keys.
+func verifyProvenance(ctx context.Context, fullRepo string, publicKeys []string, gha []*github.ReleaseAsset, assets map[string]*registry.PluginAsset) (string, registry.ProvenanceStatus, error) {
+	digests := make(map[string]map[string]string)
+	builderID := ""
+	signed := true
+	repository := "github.com/" + strings.ToLower(fullRepo)
+	for _, asset := range gha {
+		if !strings.HasSuffix(strings.ToLower(asset.GetName()), ".intoto.jsonl") {
+			continue
+		}
+		content, err := fetchAsset(ctx, asset.GetBrowserDownloadURL())
+		if err != nil {
+			return "", "", err
+		}
+		statements, err := parseProvenance(content, publicKeys)
+		if err != nil {
+			return "", "", err
+		}
+		for _, statement := range statements {
+			if normalizeSourceURI(statement.sourceURI()) != repository {
+				return "", "", verificationFailed(fmt.Errorf("provenance source %s does not match repository %s", statement.sourceURI(), fullRepo))
+			}
+			builderID = statement.builderID()
+			signed = signed && statement.signed
+			for _, subject := range statement.Subject {
+				digests[strings.ToLower(subject.Name)] = subject.Digest
+			}
+		}
+	}
+	if builderID == "" {
+		return "", "", nil
+	}
+	for _, pa := range assets {
+		digest, ok := digests[strings.ToLower(pa.FileName)]
+		if !ok {
+			return "", "", verificationFailed(fmt.Errorf("asset %s is not covered by the provenance attestation", pa.FileName))
+		}
+		if pa.Checksum == "" || digest[string(pa.GetChecksumAlgorithm())] != pa.Checksum {
+			return "", "", verificationFailed(fmt.Errorf("provenance digest of %s does not match its checksum", pa.FileName))
+		}
+	}
+	if !signed {
+		return builderID, registry.ProvenanceStatusUnverified, nil
+	}
+	return builderID, registry.ProvenanceStatusVerified, nil
+}
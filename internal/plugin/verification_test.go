@@ -0,0 +1,301 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/google/go-github/v59/github"
+	"github.com/stretchr/testify/require"
+)
+
+const testAssetName = "plugin_v1.0.0_linux_amd64"
+
+var testAssetChecksum = func() string {
+	h := sha256.Sum256([]byte("binary"))
+	return hex.EncodeToString(h[:])
+}()
+
+var testVerificationChecksumFile = []byte(testAssetChecksum + "  " + testAssetName + "\n")
+
+func newTestRelease(t *testing.T, files map[string][]byte) *github.RepositoryRelease {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path[1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(ts.Close)
+	release := &github.RepositoryRelease{TagName: github.String("v1.0.0")}
+	for name, content := range files {
+		release.Assets = append(release.Assets, &github.ReleaseAsset{
+			Name:               github.String(name),
+			Size:               github.Int(len(content)),
+			BrowserDownloadURL: github.String(fmt.Sprintf("%s/%s", ts.URL, name)),
+		})
+	}
+	return release
+}
+
+func toTestPluginRelease(release *github.RepositoryRelease, verification *Verification) (*registry.PluginRelease, error) {
+	p := &Plugin{Type: "provider", Name: "test", Repo: "owner/repo", Verification: verification}
+	return p.toPluginRelease(context.Background(), newTestLogger(), &gitHubRelease{RepositoryRelease: release}, nil)
+}
+
+func newTestMinisignKey(t *testing.T) (string, minisign.PrivateKey) {
+	publicKey, privateKey, err := minisign.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicKeyText, err := publicKey.MarshalText()
+	require.NoError(t, err)
+	return string(publicKeyText), privateKey
+}
+
+func newTestCosignKey(t *testing.T) (string, *ecdsa.PrivateKey) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), privateKey
+}
+
+func signCosign(t *testing.T, privateKey *ecdsa.PrivateKey, message []byte) []byte {
+	digest := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	require.NoError(t, err)
+	return []byte(base64.StdEncoding.EncodeToString(sig))
+}
+
+func newTestGPGKey(t *testing.T) (string, *openpgp.Entity) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String(), entity
+}
+
+// newTestProvenance returns an attestation with an invalid signature if the private key is nil.
+func newTestProvenance(t *testing.T, repo, digest string, privateKey *ecdsa.PrivateKey) []byte {
+	statement := map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject": []map[string]any{
+			{"name": testAssetName, "digest": map[string]string{"sha256": digest}},
+		},
+		"predicate": map[string]any{
+			"builder":    map[string]string{"id": "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.9.0"},
+			"invocation": map[string]any{"configSource": map[string]string{"uri": fmt.Sprintf("git+https://github.com/%s@refs/tags/v1.0.0", repo)}},
+		},
+	}
+	payload, err := json.Marshal(statement)
+	require.NoError(t, err)
+	payloadType := "application/vnd.in-toto+json"
+	sig := []byte("c2ln")
+	if privateKey != nil {
+		sig = signCosign(t, privateKey, []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)))
+	}
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures":  []map[string]string{{"keyid": "", "sig": string(sig)}},
+	})
+	require.NoError(t, err)
+	return append(envelope, '\n')
+}
+
+func TestVerifyReleaseMinisign(t *testing.T) {
+	publicKey, privateKey := newTestMinisignKey(t)
+	release := newTestRelease(t, map[string][]byte{
+		testAssetName:           []byte("binary"),
+		"checksums.txt":         testVerificationChecksumFile,
+		"checksums.txt.minisig": minisign.Sign(privateKey, testVerificationChecksumFile),
+	})
+	pr, err := toTestPluginRelease(release, &Verification{Required: true, MinisignPublicKeys: []string{publicKey}})
+	require.NoError(t, err)
+	require.True(t, pr.Verification.SignatureVerified)
+	require.Equal(t, registry.SignatureTypeMinisign, pr.Verification.SignatureType)
+	require.False(t, pr.Verification.ProvenanceVerified)
+
+	otherPublicKey, _ := newTestMinisignKey(t)
+	_, err = toTestPluginRelease(release, &Verification{MinisignPublicKeys: []string{otherPublicKey}})
+	require.ErrorContains(t, err, "invalid minisign signature")
+}
+
+func TestVerifyReleaseCosign(t *testing.T) {
+	publicKey, privateKey := newTestCosignKey(t)
+	release := newTestRelease(t, map[string][]byte{
+		testAssetName:       []byte("binary"),
+		"checksums.txt":     testVerificationChecksumFile,
+		"checksums.txt.sig": signCosign(t, privateKey, testVerificationChecksumFile),
+	})
+	pr, err := toTestPluginRelease(release, &Verification{Required: true, CosignPublicKeys: []string{publicKey}})
+	require.NoError(t, err)
+	require.True(t, pr.Verification.SignatureVerified)
+	require.Equal(t, registry.SignatureTypeCosign, pr.Verification.SignatureType)
+
+	release = newTestRelease(t, map[string][]byte{
+		testAssetName:       []byte("binary"),
+		"checksums.txt":     testVerificationChecksumFile,
+		"checksums.txt.sig": signCosign(t, privateKey, []byte("other")),
+	})
+	_, err = toTestPluginRelease(release, &Verification{CosignPublicKeys: []string{publicKey}})
+	require.ErrorContains(t, err, "invalid cosign signature")
+}
+
+func TestVerifyReleaseGPG(t *testing.T) {
+	publicKey, entity := newTestGPGKey(t)
+	var armoredSig, binarySig bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&armoredSig, entity, bytes.NewReader(testVerificationChecksumFile), nil))
+	require.NoError(t, openpgp.DetachSign(&binarySig, entity, bytes.NewReader(testVerificationChecksumFile), nil))
+
+	for _, sigFile := range []map[string][]byte{{"checksums.txt.asc": armoredSig.Bytes()}, {"checksums.txt.sig": binarySig.Bytes()}} {
+		files := map[string][]byte{
+			testAssetName:   []byte("binary"),
+			"checksums.txt": testVerificationChecksumFile,
+		}
+		for name, content := range sigFile {
+			files[name] = content
+		}
+		pr, err := toTestPluginRelease(newTestRelease(t, files), &Verification{Required: true, GPGPublicKeys: []string{publicKey}})
+		require.NoError(t, err)
+		require.True(t, pr.Verification.SignatureVerified)
+		require.Equal(t, registry.SignatureTypeGPG, pr.Verification.SignatureType)
+	}
+}
+
+func TestVerifyReleaseUnsigned(t *testing.T) {
+	publicKey, _ := newTestMinisignKey(t)
+	release := newTestRelease(t, map[string][]byte{
+		testAssetName:   []byte("binary"),
+		"checksums.txt": testVerificationChecksumFile,
+	})
+	pr, err := toTestPluginRelease(release, &Verification{MinisignPublicKeys: []string{publicKey}})
+	require.NoError(t, err)
+	require.False(t, pr.Verification.SignatureVerified)
+
+	_, err = toTestPluginRelease(release, &Verification{Required: true, MinisignPublicKeys: []string{publicKey}})
+	vErr := &VerificationError{}
+	require.ErrorAs(t, err, &vErr)
+	require.Equal(t, "1.0.0", vErr.Version)
+	require.ErrorIs(t, err, errUnsignedRelease)
+
+	// plugins without verification are not verified
+	pr, err = toTestPluginRelease(release, nil)
+	require.NoError(t, err)
+	require.Nil(t, pr.Verification)
+}
+
+func TestVerifyReleaseFetchError(t *testing.T) {
+	publicKey, _ := newTestMinisignKey(t)
+	release := newTestRelease(t, map[string][]byte{
+		testAssetName:   []byte("binary"),
+		"checksums.txt": testVerificationChecksumFile,
+	})
+	// the signature is listed, but can not be downloaded
+	release.Assets = append(release.Assets, &github.ReleaseAsset{
+		Name:               github.String("checksums.txt.minisig"),
+		BrowserDownloadURL: github.String(release.Assets[0].GetBrowserDownloadURL() + ".missing"),
+	})
+	_, err := toTestPluginRelease(release, &Verification{Required: true, MinisignPublicKeys: []string{publicKey}})
+	require.ErrorContains(t, err, "unexpected status code 404")
+	vErr := &VerificationError{}
+	require.False(t, errors.As(err, &vErr))
+}
+
+func TestVerifyReleaseProvenance(t *testing.T) {
+	publicKey, privateKey := newTestMinisignKey(t)
+	cosignPublicKey, cosignPrivateKey := newTestCosignKey(t)
+	verification := &Verification{
+		Required:           true,
+		MinisignPublicKeys: []string{publicKey},
+		CosignPublicKeys:   []string{cosignPublicKey},
+		Provenance:         true,
+	}
+	files := map[string][]byte{
+		testAssetName:           []byte("binary"),
+		"checksums.txt":         testVerificationChecksumFile,
+		"checksums.txt.minisig": minisign.Sign(privateKey, testVerificationChecksumFile),
+		"multiple.intoto.jsonl": newTestProvenance(t, "owner/repo", testAssetChecksum, cosignPrivateKey),
+	}
+	pr, err := toTestPluginRelease(newTestRelease(t, files), verification)
+	require.NoError(t, err)
+	require.True(t, pr.Verification.ProvenanceVerified)
+	require.Equal(t, registry.ProvenanceStatusVerified, pr.Verification.ProvenanceStatus)
+	require.Contains(t, pr.Verification.ProvenanceBuilderID, "slsa-github-generator")
+
+	// attestations with signatures that can not be verified are recorded, but do not satisfy Required
+	_, otherPrivateKey := newTestCosignKey(t)
+	for _, provenance := range [][]byte{
+		newTestProvenance(t, "owner/repo", testAssetChecksum, nil),
+		newTestProvenance(t, "owner/repo", testAssetChecksum, otherPrivateKey),
+	} {
+		files["multiple.intoto.jsonl"] = provenance
+		_, err = toTestPluginRelease(newTestRelease(t, files), verification)
+		require.ErrorIs(t, err, errUnverifiedProvenance)
+
+		optional := *verification
+		optional.Required = false
+		pr, err = toTestPluginRelease(newTestRelease(t, files), &optional)
+		require.NoError(t, err)
+		require.False(t, pr.Verification.ProvenanceVerified)
+		require.Equal(t, registry.ProvenanceStatusUnverified, pr.Verification.ProvenanceStatus)
+	}
+
+	for _, repo := range []string{"other/repo", "owner/repo-evil", "owner/repo/fork"} {
+		files["multiple.intoto.jsonl"] = newTestProvenance(t, repo, testAssetChecksum, cosignPrivateKey)
+		_, err = toTestPluginRelease(newTestRelease(t, files), verification)
+		require.ErrorContains(t, err, "does not match repository owner/repo")
+	}
+
+	files["multiple.intoto.jsonl"] = newTestProvenance(t, "owner/repo", hex.EncodeToString(make([]byte, sha256.Size)), cosignPrivateKey)
+	_, err = toTestPluginRelease(newTestRelease(t, files), verification)
+	require.ErrorContains(t, err, "does not match its checksum")
+
+	delete(files, "multiple.intoto.jsonl")
+	_, err = toTestPluginRelease(newTestRelease(t, files), verification)
+	require.ErrorIs(t, err, errMissingProvenance)
+
+	// without a checksum file the attestation is compared with the computed checksums
+	pr, err = toTestPluginRelease(newTestRelease(t, map[string][]byte{
+		testAssetName:           []byte("binary"),
+		"multiple.intoto.jsonl": newTestProvenance(t, "owner/repo", testAssetChecksum, cosignPrivateKey),
+	}), &Verification{CosignPublicKeys: []string{cosignPublicKey}, Provenance: true})
+	require.NoError(t, err)
+	require.True(t, pr.Verification.ProvenanceVerified)
+	require.True(t, pr.RegistryComputedChecksums)
+}
+
+func TestNormalizeSourceURI(t *testing.T) {
+	testCases := map[string]string{
+		"git+https://github.com/owner/repo@refs/tags/v1.0.0":     "github.com/owner/repo",
+		"https://github.com/Owner/Repo":                          "github.com/owner/repo",
+		"https://github.com/owner/repo.git":                      "github.com/owner/repo",
+		"git+https://github.com/owner/repo-evil@refs/heads/main": "github.com/owner/repo-evil",
+		"github.com/owner/repo/":                                 "github.com/owner/repo",
+	}
+	for uri, expected := range testCases {
+		require.Equal(t, expected, normalizeSourceURI(uri), uri)
+	}
+}
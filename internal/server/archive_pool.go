@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
+)
+
+type batchArchiveBuildFn func(ctx context.Context) (string, error)
+
+// batchArchivePool limits the number of archives that are created concurrently and
+// coalesces concurrent builds of the same archive (identified by its download hash).
+type batchArchivePool struct {
+	group     singleflight.Group
+	semaphore *semaphore.Weighted
+	// maxWait limits the time a build waits for a free slot.
+	maxWait time.Duration
+}
+
+func newBatchArchivePool(size int) *batchArchivePool {
+	return &batchArchivePool{
+		semaphore: semaphore.NewWeighted(int64(max(size, 1))),
+		maxWait:   time.Minute,
+	}
+}
+
+// do runs the build for the given archive hash at most once at a time. Callers that request the same
+// archive while a build is in flight wait for it and share its result, which is reported by the returned bool.
+// The build waits at most maxWait for a free slot, independent of the caller that started it, every caller stops
+// waiting for the shared result once its own context is done.
+func (p *batchArchivePool) do(ctx context.Context, archiveHash string, build batchArchiveBuildFn) (string, bool, error) {
+	resCh := p.group.DoChan(archiveHash, func() (any, error) {
+		// the wait is shared as well, so it must not end if the first caller goes away
+		waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.maxWait)
+		defer cancel()
+		if err := p.semaphore.Acquire(waitCtx, 1); err != nil {
+			return "", &batchArchiveError{StatusCode: http.StatusTooManyRequests, Message: "could not acquire semaphore", Err: err}
+		}
+		defer p.semaphore.Release(1)
+		// the build is shared, so it must not be canceled if the first caller goes away
+		return build(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", false, &batchArchiveError{
+			StatusCode: http.StatusServiceUnavailable,
+			Message:    "could not wait for plugin archive",
+			Err:        fmt.Errorf("waiting for archive %s: %w", archiveHash, ctx.Err()),
+		}
+	case res := <-resCh:
+		if res.Err != nil {
+			return "", res.Shared, res.Err
+		}
+		return res.Val.(string), res.Shared, nil
+	}
+}
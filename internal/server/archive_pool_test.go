@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchArchivePoolCoalescesBuilds(t *testing.T) {
+	p := newBatchArchivePool(2)
+	var builds atomic.Int32
+	release := make(chan struct{})
+	build := func(_ context.Context) (string, error) {
+		builds.Add(1)
+		<-release
+		return "checksum", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checksum, _, err := p.do(context.Background(), "hash", build)
+			require.NoError(t, err)
+			results[i] = checksum
+		}()
+	}
+	// give all callers the chance to join the in-flight build
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), builds.Load())
+	for _, checksum := range results {
+		require.Equal(t, "checksum", checksum)
+	}
+}
+
+func TestBatchArchivePoolRunsDifferentArchivesConcurrently(t *testing.T) {
+	p := newBatchArchivePool(2)
+	var running atomic.Int32
+	bothRunning := make(chan struct{})
+	build := func(_ context.Context) (string, error) {
+		if running.Add(1) == 2 {
+			close(bothRunning)
+		}
+		select {
+		case <-bothRunning:
+			return "ok", nil
+		case <-time.After(time.Second):
+			return "", fmt.Errorf("archives were not built concurrently")
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, hash := range []string{"hash1", "hash2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, shared, err := p.do(context.Background(), hash, build)
+			require.NoError(t, err)
+			require.False(t, shared)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBatchArchivePoolWaitCanceled(t *testing.T) {
+	p := newBatchArchivePool(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_, _, _ = p.do(context.Background(), "hash", func(_ context.Context) (string, error) {
+			close(started)
+			<-release
+			return "checksum", nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := p.do(ctx, "hash", func(_ context.Context) (string, error) {
+		return "other", nil
+	})
+	baErr := &batchArchiveError{}
+	require.ErrorAs(t, err, &baErr)
+	require.Equal(t, "could not wait for plugin archive", baErr.Message)
+}
+
+func TestBatchArchivePoolLimitsWaitForSlot(t *testing.T) {
+	p := newBatchArchivePool(1)
+	p.maxWait = 10 * time.Millisecond
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_, _, _ = p.do(context.Background(), "hash1", func(_ context.Context) (string, error) {
+			close(started)
+			<-release
+			return "checksum", nil
+		})
+	}()
+	<-started
+
+	_, _, err := p.do(context.Background(), "hash2", func(_ context.Context) (string, error) {
+		return "other", nil
+	})
+	baErr := &batchArchiveError{}
+	require.ErrorAs(t, err, &baErr)
+	require.Equal(t, http.StatusTooManyRequests, baErr.StatusCode)
+}
+
+func TestBatchArchivePoolWaitSurvivesFirstCaller(t *testing.T) {
+	p := newBatchArchivePool(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _, _ = p.do(context.Background(), "hash1", func(_ context.Context) (string, error) {
+			close(started)
+			<-release
+			return "checksum", nil
+		})
+	}()
+	<-started
+
+	build := func(_ context.Context) (string, error) {
+		return "other", nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := p.do(ctx, "hash2", build)
+		firstErr <- err
+	}()
+	// wait until the first caller has started the build of hash2
+	time.Sleep(10 * time.Millisecond)
+	type result struct {
+		checksum string
+		shared   bool
+		err      error
+	}
+	secondRes := make(chan result, 1)
+	go func() {
+		checksum, shared, err := p.do(context.Background(), "hash2", build)
+		secondRes <- result{checksum, shared, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	baErr := &batchArchiveError{}
+	require.ErrorAs(t, <-firstErr, &baErr)
+	require.Equal(t, "could not wait for plugin archive", baErr.Message)
+
+	close(release)
+	res := <-secondRes
+	require.NoError(t, res.err)
+	require.Equal(t, "other", res.checksum)
+	require.True(t, res.shared)
+}
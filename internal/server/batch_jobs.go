@@ -0,0 +1,310 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-semantic-release/plugin-registry/internal/plugin"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/patrickmn/go-cache"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errBatchJobQueueFull = errors.New("batch job queue is full")
+	errBatchJobNotFound  = errors.New("batch job not found")
+	errBatchJobCanceled  = errors.New("batch job has been canceled, because the registry is shutting down")
+)
+
+// batchJobTTL is the time the state of a batch job is kept after its last update.
+const batchJobTTL = time.Hour
+
+// batchJobStoreTimeout limits the time to save the state of a batch job, it does not depend on the request or
+// the workers, so that the final state is also saved while shutting down.
+const batchJobStoreTimeout = 10 * time.Second
+
+func newBatchJobID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+type batchJob struct {
+	id            string
+	batchResponse *registry.BatchResponse
+	cacheKey      cacheKey
+	logger        *logrus.Entry
+
+	mu  sync.RWMutex
+	job registry.BatchJob
+}
+
+func newBatchJob(batchResponse *registry.BatchResponse, k cacheKey, logger *logrus.Entry) *batchJob {
+	id := newBatchJobID()
+	now := time.Now().UTC()
+	return &batchJob{
+		id:            id,
+		batchResponse: batchResponse,
+		cacheKey:      k,
+		logger:        logger.WithField("batchJobID", id),
+		job: registry.BatchJob{
+			ID:        id,
+			Status:    registry.BatchJobStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+}
+
+// newCompletedBatchJob creates a job for a batch response whose archive already exists.
+func newCompletedBatchJob(batchResponse *registry.BatchResponse, logger *logrus.Entry) *batchJob {
+	job := newBatchJob(batchResponse, "", logger)
+	job.succeed()
+	return job
+}
+
+func (j *batchJob) snapshot() *registry.BatchJob {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	job := j.job
+	return &job
+}
+
+func (j *batchJob) setStatus(status registry.BatchJobStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Status = status
+	j.job.UpdatedAt = time.Now().UTC()
+}
+
+func (j *batchJob) succeed() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Status = registry.BatchJobStatusSucceeded
+	// the response is only exposed once the archive has been created, because it is modified while running
+	j.job.Response = j.batchResponse
+	j.job.UpdatedAt = time.Now().UTC()
+}
+
+func (j *batchJob) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Status = registry.BatchJobStatusFailed
+	j.job.Error = err.Error()
+	j.job.UpdatedAt = time.Now().UTC()
+}
+
+// batchJobStore persists the state of the batch jobs, so that every instance of the registry can serve it.
+type batchJobStore interface {
+	save(ctx context.Context, job *registry.BatchJob) error
+	// load returns errBatchJobNotFound if the job does not exist.
+	load(ctx context.Context, id string) (*registry.BatchJob, error)
+}
+
+type fsBatchJobData struct {
+	*registry.BatchJob
+	// ExpiresAt is used as TTL policy of the collection to remove old jobs.
+	ExpiresAt time.Time
+}
+
+type firestoreBatchJobStore struct {
+	db *firestore.Client
+}
+
+func (s *firestoreBatchJobStore) getDocRef(id string) *firestore.DocumentRef {
+	return s.db.Collection(plugin.CollectionPrefix + "-batch-jobs").Doc(id)
+}
+
+func (s *firestoreBatchJobStore) save(ctx context.Context, job *registry.BatchJob) error {
+	_, err := s.getDocRef(job.ID).Set(ctx, &fsBatchJobData{BatchJob: job, ExpiresAt: job.UpdatedAt.Add(batchJobTTL)})
+	return err
+}
+
+func (s *firestoreBatchJobStore) load(ctx context.Context, id string) (*registry.BatchJob, error) {
+	doc, err := s.getDocRef(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, errBatchJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	jobData := fsBatchJobData{BatchJob: &registry.BatchJob{}}
+	if err := doc.DataTo(&jobData); err != nil {
+		return nil, err
+	}
+	if time.Now().After(jobData.ExpiresAt) {
+		// the TTL policy removes expired documents with a delay
+		return nil, errBatchJobNotFound
+	}
+	return jobData.BatchJob, nil
+}
+
+type batchJobRunner func(ctx context.Context, job *batchJob) error
+
+// batchJobManager processes asynchronous batch jobs with a fixed number of workers. The state of the jobs is saved
+// in the store, the jobs of this instance are additionally kept in memory.
+type batchJobManager struct {
+	jobs   *cache.Cache
+	store  batchJobStore
+	queue  chan *batchJob
+	run    batchJobRunner
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBatchJobManager(store batchJobStore, workers, queueSize int, run batchJobRunner) *batchJobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &batchJobManager{
+		jobs:   cache.New(batchJobTTL, 2*batchJobTTL),
+		store:  store,
+		queue:  make(chan *batchJob, max(queueSize, 1)),
+		run:    run,
+		cancel: cancel,
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		m.wg.Add(1)
+		go m.worker(ctx)
+	}
+	return m
+}
+
+func (m *batchJobManager) worker(ctx context.Context) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-m.queue:
+			// the queue may still be selected after the manager has been stopped
+			if ctx.Err() != nil {
+				m.cancelJob(job)
+				return
+			}
+			m.process(ctx, job)
+		}
+	}
+}
+
+// save stores the current state of the job.
+func (m *batchJobManager) save(job *batchJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), batchJobStoreTimeout)
+	defer cancel()
+	return m.store.save(ctx, job.snapshot())
+}
+
+// saveOrLog stores the state of a job that is processed in the background, where errors can only be logged.
+func (m *batchJobManager) saveOrLog(job *batchJob) {
+	if err := m.save(job); err != nil {
+		job.logger.Errorf("could not save batch job: %v", err)
+	}
+}
+
+func (m *batchJobManager) process(ctx context.Context, job *batchJob) {
+	job.setStatus(registry.BatchJobStatusRunning)
+	m.saveOrLog(job)
+	if err := m.run(ctx, job); err != nil {
+		job.fail(err)
+	} else {
+		job.succeed()
+	}
+	m.saveOrLog(job)
+}
+
+// add registers a job without queueing it.
+func (m *batchJobManager) add(job *batchJob) error {
+	m.jobs.SetDefault(job.id, job)
+	if err := m.save(job); err != nil {
+		m.jobs.Delete(job.id)
+		return err
+	}
+	return nil
+}
+
+func (m *batchJobManager) submit(job *batchJob) error {
+	if err := m.add(job); err != nil {
+		return err
+	}
+	select {
+	case m.queue <- job:
+		return nil
+	default:
+		// the job has already been saved, so it must not stay pending
+		job.fail(errBatchJobQueueFull)
+		m.saveOrLog(job)
+		return errBatchJobQueueFull
+	}
+}
+
+func (m *batchJobManager) cancelJob(job *batchJob) {
+	job.fail(errBatchJobCanceled)
+	m.saveOrLog(job)
+}
+
+// get returns the state of the job, jobs of other instances are loaded from the store.
+func (m *batchJobManager) get(ctx context.Context, id string) (*registry.BatchJob, error) {
+	if job, ok := m.jobs.Get(id); ok {
+		return job.(*batchJob).snapshot(), nil
+	}
+	return m.store.load(ctx, id)
+}
+
+// stop waits for the running jobs. Queued jobs are not processed anymore, they are marked as failed, so that
+// they do not stay pending.
+func (m *batchJobManager) stop() {
+	m.cancel()
+	m.wg.Wait()
+	for {
+		select {
+		case job := <-m.queue:
+			m.cancelJob(job)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) runBatchJob(ctx context.Context, job *batchJob) error {
+	job.logger.Infof("running batch job for %s", job.batchResponse.DownloadHash)
+	err := s.createBatchArchive(ctx, job.logger, job.batchResponse, job.cacheKey)
+	if err == nil {
+		job.logger.Info("batch job finished")
+		return nil
+	}
+	job.logger.Errorf("batch job failed: %v", err)
+	// only expose the public error message
+	baErr := &batchArchiveError{}
+	if errors.As(err, &baErr) {
+		return errors.New(baErr.Message)
+	}
+	return errors.New("could not create plugin archive")
+}
+
+func (s *Server) writeBatchJob(w http.ResponseWriter, r *http.Request, job *batchJob) {
+	w.Header().Set("Location", fmt.Sprintf("/api/v%d/plugins/_batch/jobs/%s", getAPIVersion(r), job.id))
+	s.setContentTypeJSON(w)
+	w.WriteHeader(http.StatusAccepted)
+	s.writeJSON(w, r, job.snapshot())
+}
+
+func (s *Server) getBatchJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+	job, err := s.batchJobs.get(r.Context(), jobID)
+	if errors.Is(err, errBatchJobNotFound) {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("batch job %s not found", jobID))
+		return
+	} else if err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get batch job")
+		return
+	}
+	s.writeJSON(w, r, job)
+}
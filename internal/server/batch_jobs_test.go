@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBatchJobStore replaces Firestore in the tests, it can be shared by several managers like the instances of the registry.
+type memoryBatchJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]registry.BatchJob
+}
+
+func newMemoryBatchJobStore() *memoryBatchJobStore {
+	return &memoryBatchJobStore{jobs: make(map[string]registry.BatchJob)}
+}
+
+func (s *memoryBatchJobStore) save(_ context.Context, job *registry.BatchJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *memoryBatchJobStore) load(_ context.Context, id string) (*registry.BatchJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, errBatchJobNotFound
+	}
+	return &job, nil
+}
+
+func newTestBatchJob() *batchJob {
+	log := logrus.New()
+	log.Out = io.Discard
+	return newBatchJob(&registry.BatchResponse{OS: "linux", Arch: "amd64"}, "batch/test", logrus.NewEntry(log))
+}
+
+func waitForBatchJob(t *testing.T, job *batchJob) *registry.BatchJob {
+	require.Eventually(t, func() bool {
+		return job.snapshot().IsDone()
+	}, time.Second, time.Millisecond)
+	return job.snapshot()
+}
+
+func TestBatchJobManager(t *testing.T) {
+	store := newMemoryBatchJobStore()
+	m := newBatchJobManager(store, 2, 10, func(_ context.Context, job *batchJob) error {
+		if job.batchResponse.Arch == "arm64" {
+			return errors.New("could not create plugin archive")
+		}
+		job.batchResponse.DownloadChecksum = "checksum"
+		return nil
+	})
+	defer m.stop()
+
+	job := newTestBatchJob()
+	require.Equal(t, registry.BatchJobStatusPending, job.snapshot().Status)
+	require.NoError(t, m.submit(job))
+	res := waitForBatchJob(t, job)
+	require.Equal(t, registry.BatchJobStatusSucceeded, res.Status)
+	foundJob, err := m.get(context.Background(), job.id)
+	require.NoError(t, err)
+	require.Equal(t, registry.BatchJobStatusSucceeded, foundJob.Status)
+	require.Equal(t, "checksum", foundJob.Response.DownloadChecksum)
+
+	// other instances load the job from the store
+	other := newBatchJobManager(store, 1, 1, nil)
+	defer other.stop()
+	foundJob, err = other.get(context.Background(), job.id)
+	require.NoError(t, err)
+	require.Equal(t, registry.BatchJobStatusSucceeded, foundJob.Status)
+	require.Equal(t, "checksum", foundJob.Response.DownloadChecksum)
+
+	failingJob := newTestBatchJob()
+	failingJob.batchResponse.Arch = "arm64"
+	require.NoError(t, m.submit(failingJob))
+	res = waitForBatchJob(t, failingJob)
+	require.Equal(t, registry.BatchJobStatusFailed, res.Status)
+	require.Equal(t, "could not create plugin archive", res.Error)
+	require.Nil(t, res.Response)
+
+	_, err = m.get(context.Background(), "unknown")
+	require.ErrorIs(t, err, errBatchJobNotFound)
+}
+
+func TestBatchJobManagerQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	m := newBatchJobManager(newMemoryBatchJobStore(), 1, 1, func(_ context.Context, _ *batchJob) error {
+		<-block
+		return nil
+	})
+	defer m.stop()
+	defer close(block)
+
+	// the first job is picked up by the worker, the second one fills the queue
+	require.NoError(t, m.submit(newTestBatchJob()))
+	require.Eventually(t, func() bool { return len(m.queue) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, m.submit(newTestBatchJob()))
+
+	job := newTestBatchJob()
+	require.ErrorIs(t, m.submit(job), errBatchJobQueueFull)
+	res, err := m.get(context.Background(), job.id)
+	require.NoError(t, err)
+	require.Equal(t, registry.BatchJobStatusFailed, res.Status)
+}
+
+func TestBatchJobManagerStop(t *testing.T) {
+	started := make(chan struct{})
+	m := newBatchJobManager(newMemoryBatchJobStore(), 1, 1, func(ctx context.Context, _ *batchJob) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	runningJob := newTestBatchJob()
+	require.NoError(t, m.submit(runningJob))
+	<-started
+	queuedJob := newTestBatchJob()
+	require.NoError(t, m.submit(queuedJob))
+	m.stop()
+
+	for _, job := range []*batchJob{runningJob, queuedJob} {
+		res, err := m.get(context.Background(), job.id)
+		require.NoError(t, err)
+		require.Equal(t, registry.BatchJobStatusFailed, res.Status)
+	}
+	res, err := m.store.load(context.Background(), queuedJob.id)
+	require.NoError(t, err)
+	require.Equal(t, errBatchJobCanceled.Error(), res.Error)
+}
+
+func TestWriteBatchJobLocation(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+	job := newCompletedBatchJob(&registry.BatchResponse{OS: "linux", Arch: "amd64"}, logrus.NewEntry(s.log))
+
+	for _, version := range []int{2, 3} {
+		var r *http.Request
+		withAPIVersion(version)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			r = req
+		})).ServeHTTP(nil, httptest.NewRequest(http.MethodPost, "/plugins/_batch?async=true", nil))
+		rr := httptest.NewRecorder()
+		s.writeBatchJob(rr, r, job)
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		require.Equal(t, fmt.Sprintf("/api/v%d/plugins/_batch/jobs/%s", version, job.id), rr.Header().Get("Location"))
+	}
+}
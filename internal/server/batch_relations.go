@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+// resolvedPlugin is a plugin of a batch together with its resolved release.
+type resolvedPlugin struct {
+	response *registry.BatchResponsePlugin
+	release  *registry.PluginRelease
+}
+
+// canonicalPluginName returns the full name of the plugin, so that aliases refer to the same plugin.
+func canonicalPluginName(name string) string {
+	if p := config.Plugins.Find(name); p != nil {
+		return p.GetFullName()
+	}
+	return strings.ToLower(name)
+}
+
+// missingRequiredPlugins returns the plugins that are required by the newly resolved plugins, but are not part
+// of the batch. Unknown plugins are not added, they are reported by checkPluginRelations.
+func missingRequiredPlugins(resolved map[string]*resolvedPlugin, newPlugins registry.BatchResponsePlugins) registry.BatchResponsePlugins {
+	missing := make(registry.BatchResponsePlugins, 0)
+	for _, pluginResponse := range newPlugins {
+		release := resolved[canonicalPluginName(pluginResponse.FullName)].release
+		for _, required := range release.Requires {
+			p := config.Plugins.Find(required.FullName)
+			if p == nil || resolved[p.GetFullName()] != nil || missing.Has(p.GetFullName()) {
+				continue
+			}
+			versionConstraint := required.VersionConstraint
+			if versionConstraint == "" {
+				versionConstraint = "latest"
+			}
+			missingPlugin := registry.NewBatchResponsePlugin(&registry.BatchRequestPlugin{
+				FullName:          p.GetFullName(),
+				VersionConstraint: versionConstraint,
+			})
+			missingPlugin.RequiredBy = pluginResponse.FullName
+			missing = append(missing, missingPlugin)
+		}
+	}
+	return missing
+}
+
+// checkPluginRelations verifies that the required plugins of all resolved releases are part of the batch
+// in a matching version and that the batch does not contain conflicting plugins.
+func checkPluginRelations(plugins registry.BatchResponsePlugins, resolved map[string]*resolvedPlugin) error {
+	for _, pluginResponse := range plugins {
+		rp := resolved[canonicalPluginName(pluginResponse.FullName)]
+		name := fmt.Sprintf("%s@%s", pluginResponse.FullName, rp.release.Version)
+		for _, required := range rp.release.Requires {
+			other := resolved[canonicalPluginName(required.FullName)]
+			if other == nil {
+				return &pluginBatchError{
+					PluginName: pluginResponse.FullName,
+					Err:        fmt.Errorf("%s requires %s, which is not part of the batch (set AutoComplete to add it)", name, required),
+				}
+			}
+			if !required.Matches(other.release.Version) {
+				return &pluginBatchError{
+					PluginName: pluginResponse.FullName,
+					Err:        fmt.Errorf("%s requires %s, but %s@%s is resolved", name, required, other.response.FullName, other.release.Version),
+				}
+			}
+		}
+		for _, conflicting := range rp.release.Conflicts {
+			other := resolved[canonicalPluginName(conflicting.FullName)]
+			if other == nil || other == rp || !conflicting.Matches(other.release.Version) {
+				continue
+			}
+			return &pluginBatchError{
+				PluginName: pluginResponse.FullName,
+				Err:        fmt.Errorf("%s conflicts with %s@%s", name, other.response.FullName, other.release.Version),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResolvedPlugin(fullName, version string, requires, conflicts []*registry.PluginRelation) *resolvedPlugin {
+	pluginResponse := registry.NewBatchResponsePlugin(&registry.BatchRequestPlugin{FullName: fullName, VersionConstraint: "latest"})
+	pluginResponse.Version = version
+	return &resolvedPlugin{
+		response: pluginResponse,
+		release:  &registry.PluginRelease{Version: version, Requires: requires, Conflicts: conflicts},
+	}
+}
+
+func newTestResolvedPlugins(plugins ...*resolvedPlugin) (registry.BatchResponsePlugins, map[string]*resolvedPlugin) {
+	responses := make(registry.BatchResponsePlugins, 0, len(plugins))
+	resolved := make(map[string]*resolvedPlugin, len(plugins))
+	for _, rp := range plugins {
+		responses = append(responses, rp.response)
+		resolved[canonicalPluginName(rp.response.FullName)] = rp
+	}
+	return responses, resolved
+}
+
+func TestMissingRequiredPlugins(t *testing.T) {
+	plugins, resolved := newTestResolvedPlugins(
+		newTestResolvedPlugin("hooks-goreleaser", "1.0.0", []*registry.PluginRelation{
+			{FullName: "provider-github", VersionConstraint: "^1.0.0"},
+			{FullName: "commit-analyzer-default"},
+			{FullName: "condition-github"},
+			{FullName: "provider-unknown"},
+		}, nil),
+		newTestResolvedPlugin("hooks-exec", "1.0.0", []*registry.PluginRelation{{FullName: "provider-github"}}, nil),
+		newTestResolvedPlugin("condition-github", "1.0.0", nil, nil),
+	)
+	missing := missingRequiredPlugins(resolved, plugins)
+	require.Len(t, missing, 2)
+	require.Equal(t, "provider-github", missing[0].FullName)
+	require.Equal(t, "^1.0.0", missing[0].VersionConstraint)
+	require.Equal(t, "hooks-goreleaser", missing[0].RequiredBy)
+	// aliases are resolved to the full name
+	require.Equal(t, "commit-analyzer-cz", missing[1].FullName)
+	require.Equal(t, "latest", missing[1].VersionConstraint)
+}
+
+func TestCheckPluginRelations(t *testing.T) {
+	requiresGitHub := []*registry.PluginRelation{{FullName: "provider-github", VersionConstraint: "^1.0.0"}}
+	conflictsNpm := []*registry.PluginRelation{{FullName: "hooks-npm-binary-releaser", VersionConstraint: "< 2.0.0"}}
+
+	require.NoError(t, checkPluginRelations(newTestResolvedPlugins(
+		newTestResolvedPlugin("hooks-goreleaser", "1.0.0", requiresGitHub, conflictsNpm),
+		newTestResolvedPlugin("provider-github", "1.5.0", nil, nil),
+		newTestResolvedPlugin("hooks-npm-binary-releaser", "2.1.0", nil, nil),
+	)))
+
+	err := checkPluginRelations(newTestResolvedPlugins(
+		newTestResolvedPlugin("hooks-goreleaser", "1.0.0", requiresGitHub, nil),
+	))
+	require.ErrorContains(t, err, "hooks-goreleaser@1.0.0 requires provider-github@^1.0.0, which is not part of the batch")
+
+	err = checkPluginRelations(newTestResolvedPlugins(
+		newTestResolvedPlugin("hooks-goreleaser", "1.0.0", requiresGitHub, nil),
+		newTestResolvedPlugin("provider-github", "2.0.0", nil, nil),
+	))
+	require.ErrorContains(t, err, "hooks-goreleaser@1.0.0 requires provider-github@^1.0.0, but provider-github@2.0.0 is resolved")
+
+	err = checkPluginRelations(newTestResolvedPlugins(
+		newTestResolvedPlugin("hooks-goreleaser", "1.0.0", nil, conflictsNpm),
+		newTestResolvedPlugin("hooks-npm-binary-releaser", "1.9.0", nil, nil),
+	))
+	pbErr := &pluginBatchError{}
+	require.ErrorAs(t, err, &pbErr)
+	require.Equal(t, "hooks-goreleaser", pbErr.PluginName)
+	require.ErrorContains(t, err, "hooks-goreleaser@1.0.0 conflicts with hooks-npm-binary-releaser@1.9.0")
+}
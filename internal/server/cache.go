@@ -1,13 +1,17 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-semantic-release/plugin-registry/internal/metrics"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
 	"github.com/patrickmn/go-cache"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
@@ -30,13 +34,28 @@ const (
 )
 
 func (s *Server) getCacheKeyFromRequest(r *http.Request) cacheKey {
-	return cacheKey(fmt.Sprintf("%s/%s:%s", cacheKeyPrefixRequest, r.Method, r.URL.EscapedPath()))
+	// the cached values are converted to the representation of the API version when they are written,
+	// so the v3 routes share the cache entries (and their invalidation) with the v2 routes
+	path := r.URL.EscapedPath()
+	if rest, ok := strings.CutPrefix(path, "/api/v3/"); ok {
+		path = "/api/v2/" + rest
+	}
+	k := fmt.Sprintf("%s/%s:%s", cacheKeyPrefixRequest, r.Method, path)
+	// the query is normalized, so that the order of the parameters does not matter
+	if query := r.URL.Query().Encode(); query != "" {
+		k += "?" + query
+	}
+	return cacheKey(k)
 }
 
 func (s *Server) getCacheKeyPrefixFromPluginName(pluginName string) cacheKey {
 	return cacheKey(fmt.Sprintf("%s/%s:/api/v2/plugins/%s", cacheKeyPrefixRequest, http.MethodGet, pluginName))
 }
 
+func (s *Server) getPlatformMatrixCacheKey() cacheKey {
+	return cacheKey(fmt.Sprintf("%s/%s:/api/v2/platforms", cacheKeyPrefixRequest, http.MethodGet))
+}
+
 func (s *Server) getCacheKeyWithPrefix(p cacheKeyPrefix, key string) cacheKey {
 	return cacheKey(fmt.Sprintf("%s/%s", p, key))
 }
@@ -63,6 +82,13 @@ func (s *Server) setInCache(ctx context.Context, k cacheKey, v any, expiration .
 	s.cache.Set(string(k), v, exp)
 }
 
+// invalidatePluginListCache invalidates the filtered and expanded plugin lists, which contain the latest releases.
+func (s *Server) invalidatePluginListCache() {
+	for _, path := range []string{"/api/v2/plugins?", "/api/v2/plugins/?"} {
+		s.invalidateByPrefix(cacheKey(fmt.Sprintf("%s/%s:%s", cacheKeyPrefixRequest, http.MethodGet, path)))
+	}
+}
+
 func (s *Server) invalidateByPrefix(prefix cacheKey) int {
 	cnt := 0
 	for k := range s.cache.Items() {
@@ -74,15 +100,107 @@ func (s *Server) invalidateByPrefix(prefix cacheKey) int {
 	return cnt
 }
 
+type encodedResponseKey struct {
+	apiVersion int
+	encoding   string
+}
+
+type encodedResponse struct {
+	body []byte
+	etag string
+	// encoding is empty if the body is not compressed.
+	encoding string
+}
+
+// cachedResponse is the cached value of a request. The value is encoded once per API version and content encoding,
+// so that cache hits do not encode (and compress) the value again.
+type cachedResponse struct {
+	value        any
+	lastModified time.Time
+
+	mu      sync.Mutex
+	encoded map[encodedResponseKey]*encodedResponse
+}
+
+func newCachedResponse(v any) *cachedResponse {
+	return &cachedResponse{
+		value:        v,
+		lastModified: lastModified(v),
+		encoded:      make(map[encodedResponseKey]*encodedResponse),
+	}
+}
+
+func (cr *cachedResponse) encode(apiVersion int, encoding string) (*encodedResponse, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.encodeLocked(encodedResponseKey{apiVersion: apiVersion, encoding: encoding})
+}
+
+func (cr *cachedResponse) encodeLocked(k encodedResponseKey) (*encodedResponse, error) {
+	if er, ok := cr.encoded[k]; ok {
+		return er, nil
+	}
+	var er *encodedResponse
+	if k.encoding != "" {
+		// the compressed body is derived from the uncompressed one, small bodies are not compressed
+		identity, err := cr.encodeLocked(encodedResponseKey{apiVersion: k.apiVersion})
+		if err != nil {
+			return nil, err
+		}
+		er = identity
+		if len(identity.body) >= minCompressSize {
+			er = &encodedResponse{
+				body:     compressBody(k.encoding, identity.body),
+				etag:     "W/" + identity.etag,
+				encoding: k.encoding,
+			}
+		}
+	} else {
+		v := cr.value
+		if k.apiVersion == 3 {
+			v = apiv3.Convert(v)
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		er = &encodedResponse{body: buf.Bytes(), etag: computeETag(buf.Bytes())}
+	}
+	cr.encoded[k] = er
+	return er, nil
+}
+
+// cacheResponse caches the response value of the request for the cacheMiddleware.
+func (s *Server) cacheResponse(r *http.Request, v any) {
+	s.setInCache(r.Context(), s.getCacheKeyFromRequest(r), newCachedResponse(v))
+}
+
+func (s *Server) writeCachedResponse(w http.ResponseWriter, r *http.Request, cr *cachedResponse) {
+	er, err := cr.encode(getAPIVersion(r), negotiateEncoding(r.Header.Get("Accept-Encoding")))
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not encode response")
+		return
+	}
+	w.Header().Set("X-Go-Cache", "HIT")
+	s.setContentTypeJSON(w)
+	w.Header().Set("ETag", er.etag)
+	if !cr.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", cr.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if er.encoding != "" {
+		w.Header().Set("Content-Encoding", er.encoding)
+	}
+	_, _ = w.Write(er.body)
+}
+
 func (s *Server) cacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if s.config.DisableRequestCache {
 			next.ServeHTTP(w, r)
 			return
 		}
-		if k, ok := s.getFromCache(r.Context(), s.getCacheKeyFromRequest(r)); ok {
-			w.Header().Set("X-Go-Cache", "HIT")
-			s.writeJSON(w, k)
+		if v, ok := s.getFromCache(r.Context(), s.getCacheKeyFromRequest(r)); ok {
+			s.writeCachedResponse(w, r, v.(*cachedResponse))
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize is the minimum size of a response body to be compressed, smaller bodies are sent as they are.
+const minCompressSize = 1024
+
+// brotliLevel trades the compression ratio for speed, as responses are compressed on the fly.
+const brotliLevel = 5
+
+// compressionEncodings are the supported content encodings in the order of preference.
+var compressionEncodings = []string{"zstd", "br", "gzip"}
+
+// encoder is implemented by the gzip, zstd and brotli writers, which are reused with Reset.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"zstd": {
+		New: func() any {
+			e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return e
+		},
+	},
+	"br": {
+		New: func() any {
+			return brotli.NewWriterLevel(nil, brotliLevel)
+		},
+	},
+	"gzip": {
+		New: func() any {
+			return gzip.NewWriter(nil)
+		},
+	},
+}
+
+func getEncoder(encoding string, w io.Writer) encoder {
+	e := encoderPools[encoding].Get().(encoder)
+	e.Reset(w)
+	return e
+}
+
+func putEncoder(encoding string, e encoder) {
+	encoderPools[encoding].Put(e)
+}
+
+// compressBody encodes the data with the content encoding.
+func compressBody(encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	e := getEncoder(encoding, &buf)
+	defer putEncoder(encoding, e)
+	// writing to a bytes.Buffer does not fail
+	_, _ = e.Write(data)
+	_ = e.Close()
+	return buf.Bytes()
+}
+
+// negotiateEncoding returns the supported content encoding with the highest quality in the Accept-Encoding header
+// or an empty string if the response should not be compressed.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[name] = q
+	}
+	bestEncoding, bestQuality := "", 0.0
+	for _, encoding := range compressionEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQuality {
+			bestEncoding, bestQuality = encoding, q
+		}
+	}
+	return bestEncoding
+}
+
+func isCompressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/")
+}
+
+// compressResponseWriter compresses the response with the negotiated encoding. Only the first minCompressSize bytes
+// are buffered to skip small bodies, larger bodies are streamed through the encoder. Bodies that are not compressible
+// or already encoded (e.g. by the cache) are passed through.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding   string
+	statusCode int
+	buf        []byte
+	// started is set once the header has been written to the underlying writer.
+	started bool
+	encoder encoder
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.statusCode == 0 {
+		cw.statusCode = statusCode
+	}
+}
+
+func (cw *compressResponseWriter) shouldCompress() bool {
+	header := cw.Header()
+	return header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) &&
+		cw.statusCode != http.StatusNoContent && cw.statusCode != http.StatusNotModified
+}
+
+// start writes the header and the buffered body to the underlying writer.
+func (cw *compressResponseWriter) start(compress bool) error {
+	cw.started = true
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+	if compress {
+		header := cw.Header()
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		// the entity tag of the payload does not identify the encoded representation
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.encoder = getEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+	if !cw.started {
+		if !cw.shouldCompress() {
+			if err := cw.start(false); err != nil {
+				return 0, err
+			}
+			return cw.ResponseWriter.Write(b)
+		}
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < minCompressSize {
+			return len(b), nil
+		}
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if !cw.started {
+		_ = cw.start(cw.shouldCompress())
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) finish() {
+	if !cw.started {
+		// the body is smaller than minCompressSize
+		_ = cw.start(false)
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		putEncoder(cw.encoding, cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+// compressMiddleware compresses the responses with zstd, brotli or gzip if the client accepts it.
+func (s *Server) compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip, deflate, br", "br"},
+		{"zstd;q=0.5, gzip", "gzip"},
+		{"GZIP;q=0.8", "gzip"},
+		{"zstd;q=0, gzip;q=0", ""},
+		{"br;q=0.5, *;q=0.1", "br"},
+		{"deflate, *;q=0.1", "zstd"},
+		{"*;q=0", ""},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, negotiateEncoding(tc.acceptEncoding), tc.acceptEncoding)
+	}
+}
+
+// decodeBody returns the body without its content encoding.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(strings.NewReader(string(body)))
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	case "zstd":
+		zr, err := zstd.NewReader(strings.NewReader(string(body)))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "br":
+		r = brotli.NewReader(strings.NewReader(string(body)))
+	default:
+		return body, nil
+	}
+	return io.ReadAll(r)
+}
+
+func decompress(t *testing.T, encoding string, body []byte) []byte {
+	data, err := decodeBody(encoding, body)
+	require.NoError(t, err)
+	return data
+}
+
+func withAcceptEncoding(encoding string) func(req *http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	uncompressed := sendRequest(s, "GET", "/api/v3/openapi.json", nil)
+	require.Equal(t, http.StatusOK, uncompressed.Code)
+	require.Empty(t, uncompressed.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", uncompressed.Header().Get("Vary"))
+
+	for _, encoding := range compressionEncodings {
+		rr := sendRequest(s, "GET", "/api/v3/openapi.json", nil, withAcceptEncoding(encoding))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, encoding, rr.Header().Get("Content-Encoding"))
+		require.Less(t, rr.Body.Len(), uncompressed.Body.Len())
+		require.Equal(t, uncompressed.Body.Bytes(), decompress(t, encoding, rr.Body.Bytes()))
+		require.Equal(t, "W/"+uncompressed.Header().Get("ETag"), rr.Header().Get("ETag"))
+
+		rr = sendRequest(s, "GET", "/api/v3/openapi.json", nil, withAcceptEncoding(encoding), func(req *http.Request) {
+			req.Header.Set("If-None-Match", "W/"+uncompressed.Header().Get("ETag"))
+		})
+		require.Equal(t, http.StatusNotModified, rr.Code)
+		require.Empty(t, rr.Header().Get("Content-Encoding"))
+	}
+
+	// small responses are not compressed
+	rr := sendRequest(s, "GET", "/api/v2/keys", nil, withAcceptEncoding("gzip"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("Content-Encoding"))
+	require.Equal(t, "[]\n", rr.Body.String())
+}
+
+// headerRecorder records whether the header has been written while the handler is running.
+type headerRecorder struct {
+	*httptest.ResponseRecorder
+	headerWritten bool
+}
+
+func (hr *headerRecorder) WriteHeader(statusCode int) {
+	hr.headerWritten = true
+	hr.ResponseRecorder.WriteHeader(statusCode)
+}
+
+func TestCompressionStreamsLargeBodies(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	chunk := bytes.Repeat([]byte(`{"a":"b"}`), minCompressSize/8)
+	hr := &headerRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler := s.compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(chunk[:minCompressSize/2])
+		// small bodies are buffered until the minimum size is reached
+		require.False(t, hr.headerWritten)
+		_, _ = w.Write(chunk[minCompressSize/2:])
+		require.True(t, hr.headerWritten)
+		_, _ = w.Write(chunk)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	handler.ServeHTTP(hr, req)
+	require.Equal(t, "br", hr.Header().Get("Content-Encoding"))
+	require.Equal(t, append(append([]byte{}, chunk...), chunk...), decompress(t, "br", hr.Body.Bytes()))
+}
+
+func TestCompressionSkipsNonCompressibleBodies(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	body := bytes.Repeat([]byte("a"), 2*minCompressSize)
+	hr := &headerRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler := s.compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(body[:10])
+		// non-compressible bodies are passed through without buffering
+		require.True(t, hr.headerWritten)
+		_, _ = w.Write(body[10:])
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(hr, req)
+	require.Empty(t, hr.Header().Get("Content-Encoding"))
+	require.Equal(t, body, hr.Body.Bytes())
+}
+
+func TestCachedResponsesArePreEncoded(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+	s.config.DisableRequestCache = false
+
+	plugin := &registry.Plugin{
+		FullName:      "provider-git",
+		Description:   strings.Repeat("A git provider. ", 100),
+		LatestRelease: &registry.PluginRelease{Version: "1.0.0"},
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v2/plugins/provider-git", nil)
+	require.NoError(t, err)
+	s.cacheResponse(req, plugin)
+	v, ok := s.getFromCache(req.Context(), s.getCacheKeyFromRequest(req))
+	require.True(t, ok)
+	cr := v.(*cachedResponse)
+
+	uncompressed := sendRequest(s, "GET", "/api/v2/plugins/provider-git", nil)
+	require.Equal(t, http.StatusOK, uncompressed.Code)
+	require.Equal(t, "HIT", uncompressed.Header().Get("X-Go-Cache"))
+	var cachedPlugin registry.Plugin
+	require.NoError(t, json.Unmarshal(uncompressed.Body.Bytes(), &cachedPlugin))
+	require.Equal(t, plugin.Description, cachedPlugin.Description)
+	require.Equal(t, computeETag(uncompressed.Body.Bytes()), uncompressed.Header().Get("ETag"))
+
+	rr := sendRequest(s, "GET", "/api/v2/plugins/provider-git", nil, withAcceptEncoding("gzip"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	require.Equal(t, uncompressed.Body.Bytes(), decompress(t, "gzip", rr.Body.Bytes()))
+	require.Equal(t, "W/"+uncompressed.Header().Get("ETag"), rr.Header().Get("ETag"))
+
+	// the encoded representations are stored in the cache entry
+	gzipResponse := cr.encoded[encodedResponseKey{apiVersion: 2, encoding: "gzip"}]
+	require.NotNil(t, gzipResponse)
+	require.Equal(t, gzipResponse.body, rr.Body.Bytes())
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git", nil, withAcceptEncoding("gzip"))
+	require.Equal(t, gzipResponse.body, rr.Body.Bytes())
+	require.Len(t, cr.encoded, 2)
+
+	rr = sendRequest(s, "GET", "/api/v3/plugins/provider-git", nil, withAcceptEncoding("zstd"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "zstd", rr.Header().Get("Content-Encoding"))
+	require.Contains(t, string(decompress(t, "zstd", rr.Body.Bytes())), `"fullName":"provider-git"`)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git", nil, withAcceptEncoding("gzip"), func(req *http.Request) {
+		req.Header.Set("If-None-Match", gzipResponse.etag)
+	})
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Empty(t, rr.Body.Bytes())
+}
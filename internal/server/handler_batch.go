@@ -1,12 +1,15 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -14,9 +17,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-semantic-release/plugin-registry/internal/batch"
 	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -65,13 +71,261 @@ func validateAndCreatePluginResponses(batchRequest *registry.BatchRequest) (regi
 	return pluginResponses, nil
 }
 
-//gocyclo:ignore
+type batchArchiveError struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *batchArchiveError) Error() string {
+	return fmt.Sprintf("batch archive error (%s): %s", e.Message, e.Err.Error())
+}
+
+func (e *batchArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// resolvePlugin resolves the version and the asset of the plugin for the platform (or one of its fallbacks)
+// and returns the resolved release. If the semantic-release version is set, only compatible releases are considered.
+func (s *Server) resolvePlugin(ctx context.Context, pluginResponse *registry.BatchResponsePlugin, platformKey string, semRelVersion *semver.Version) (*registry.PluginRelease, error) {
+	p := config.Plugins.Find(pluginResponse.FullName)
+	foundRelease, err := p.GetReleaseWithVersionConstraint(ctx, s.db, pluginResponse.VersionConstraint, semRelVersion)
+	if err != nil {
+		return nil, &pluginBatchError{
+			PluginName: pluginResponse.FullName,
+			Err:        err,
+		}
+	}
+	foundAsset, servedPlatform := config.PlatformFallbacks.FindAsset(foundRelease, platformKey)
+	if foundAsset == nil {
+		return nil, &pluginBatchError{
+			PluginName: pluginResponse.FullName,
+			Err:        fmt.Errorf("could not find %s asset", platformKey),
+		}
+	}
+	pluginResponse.Version = foundRelease.Version
+	pluginResponse.FileName = foundAsset.FileName
+	pluginResponse.URL = foundAsset.URL
+	pluginResponse.Checksum = foundAsset.Checksum
+	pluginResponse.ChecksumAlgorithm = foundAsset.GetChecksumAlgorithm()
+	pluginResponse.Size = foundAsset.Size
+	pluginResponse.ArchiveType = foundAsset.ArchiveType
+	pluginResponse.Platform = servedPlatform
+	return foundRelease, nil
+}
+
+// resolvePlugins resolves the plugins concurrently and returns the resolved releases in the order of the plugins.
+func (s *Server) resolvePlugins(ctx context.Context, batchResponse *registry.BatchResponse, pluginResponses registry.BatchResponsePlugins) ([]*registry.PluginRelease, error) {
+	releases := make([]*registry.PluginRelease, len(pluginResponses))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(5)
+	for i, pluginResponse := range pluginResponses {
+		i, pluginResponse := i, pluginResponse
+		errGroup.Go(func() error {
+			release, err := s.resolvePlugin(groupCtx, pluginResponse, batchResponse.GetOSArch(), batchResponse.GetSemanticReleaseVersion())
+			releases[i] = release
+			return err
+		})
+	}
+	return releases, errGroup.Wait()
+}
+
+// resolveBatchResponsePlugins resolves all plugins of the batch. If AutoComplete is set, the required plugins
+// that are not part of the batch are added and resolved as well. Finally, the relations of the resolved
+// releases are checked.
+func (s *Server) resolveBatchResponsePlugins(ctx context.Context, batchResponse *registry.BatchResponse) error {
+	resolved := make(map[string]*resolvedPlugin)
+	pending := batchResponse.Plugins
+	for len(pending) > 0 {
+		releases, err := s.resolvePlugins(ctx, batchResponse, pending)
+		if err != nil {
+			return err
+		}
+		for i, pluginResponse := range pending {
+			resolved[canonicalPluginName(pluginResponse.FullName)] = &resolvedPlugin{response: pluginResponse, release: releases[i]}
+		}
+		if !batchResponse.AutoComplete {
+			break
+		}
+		pending = missingRequiredPlugins(resolved, pending)
+		if len(resolved)+len(pending) > registry.MaxBatchPlugins {
+			return fmt.Errorf("the batch exceeds the maximum of %d plugins after adding the required plugins", registry.MaxBatchPlugins)
+		}
+		batchResponse.Plugins = append(batchResponse.Plugins, pending...)
+	}
+	sort.Sort(batchResponse.Plugins)
+	return checkPluginRelations(batchResponse.Plugins, resolved)
+}
+
+// batchArchiveKeyVersion is incremented whenever the content of the archives changes for the same DownloadHash,
+// so that archives with the old content are not served. The DownloadHash itself stays stable for the clients.
+const batchArchiveKeyVersion = 2
+
+func getBatchArchiveKey(batchResponse *registry.BatchResponse) string {
+	return fmt.Sprintf("archives/v%d/plugins-%s.%s", batchArchiveKeyVersion, batchResponse.DownloadHash, batchResponse.GetFormat())
+}
+
+func getBatchArchiveSignatureKey(batchResponse *registry.BatchResponse) string {
+	return getBatchArchiveKey(batchResponse) + ".sig"
+}
+
+func isS3NotFoundError(err error) bool {
+	var s3ResponseError *awshttp.ResponseError
+	return errors.As(err, &s3ResponseError) && s3ResponseError.HTTPStatusCode() == http.StatusNotFound
+}
+
+// uploadBatchArchiveSignature uploads the detached signature of the archive next to it. If onlyIfMissing is set,
+// an existing signature is kept, this is the case for archives that have been created before signing was enabled.
+func (s *Server) uploadBatchArchiveSignature(ctx context.Context, batchResponse *registry.BatchResponse, checksum string, onlyIfMissing bool) error {
+	signatureKey := getBatchArchiveSignatureKey(batchResponse)
+	if onlyIfMissing {
+		_, err := s.storage.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: s.config.GetBucket(),
+			Key:    &signatureKey,
+		})
+		if err == nil {
+			return nil
+		}
+		if !isS3NotFoundError(err) {
+			return err
+		}
+	}
+	signature, err := json.Marshal(s.signer.SignArchive(checksum))
+	if err != nil {
+		return err
+	}
+	_, err = s.storage.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      s.config.GetBucket(),
+		Key:         &signatureKey,
+		Body:        bytes.NewReader(signature),
+		ContentType: aws.String("application/json"),
+		Metadata: map[string]string{
+			"checksum": checksum,
+			"key_id":   s.signer.PublicKey().KeyID,
+		},
+	})
+	return err
+}
+
+// createBatchArchive ensures that the archive for the resolved batch response exists in the storage
+// and sets the download checksum of the batch response.
+func (s *Server) createBatchArchive(ctx context.Context, reqLogger *logrus.Entry, batchResponse *registry.BatchResponse, batchRequestCacheKey cacheKey) error {
+	checksum, shared, err := s.batchArchives.do(ctx, batchResponse.DownloadHash, func(buildCtx context.Context) (string, error) {
+		return s.buildBatchArchive(buildCtx, reqLogger, batchResponse, batchRequestCacheKey)
+	})
+	if err != nil {
+		return err
+	}
+	if shared {
+		reqLogger.Infof("shared plugin archive build for %s", batchResponse.DownloadHash)
+	}
+	batchResponse.DownloadChecksum = checksum
+	if s.signer != nil {
+		s.signer.SignBatchResponse(batchResponse)
+	}
+	s.setInCache(ctx, batchRequestCacheKey, batchResponse)
+	return nil
+}
+
+// buildBatchArchive creates and uploads the archive if it does not exist yet and returns its checksum.
+func (s *Server) buildBatchArchive(ctx context.Context, reqLogger *logrus.Entry, batchResponse *registry.BatchResponse, batchRequestCacheKey cacheKey) (string, error) {
+	archiveKey := getBatchArchiveKey(batchResponse)
+	headRes, err := s.storage.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: s.config.GetBucket(),
+		Key:    &archiveKey,
+	})
+	if err == nil {
+		// the archive already exists
+		reqLogger.Infof("found cached archive %s", archiveKey)
+		checksum := headRes.Metadata["checksum"]
+		if s.signer != nil {
+			if err := s.uploadBatchArchiveSignature(ctx, batchResponse, checksum, true); err != nil {
+				return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not upload plugin archive signature", Err: err}
+			}
+		}
+		return checksum, nil
+	}
+
+	if !isS3NotFoundError(err) {
+		reqLogger.Errorf("could not check if plugin archive exists: %v", err)
+		return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not check if plugin archive exists", Err: err}
+	}
+
+	reqLogger.Infof("plugin archive %s not found, creating (%d plugins for %s)...", archiveKey, len(batchResponse.Plugins), batchResponse.GetOSArch())
+	archiveFileName, archiveChecksum, err := batch.DownloadFilesAndArchive(ctx, batchResponse, &batch.Options{
+		Concurrency: s.config.BatchDownloadConcurrency,
+		BlobCache:   s.blobCache,
+	})
+	if err != nil {
+		return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not create plugin archive", Err: err}
+	}
+	reqLogger.Infof("created plugin archive %s, uploading...", archiveFileName)
+	archiveFile, err := os.Open(archiveFileName)
+	if err != nil {
+		return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not open plugin archive", Err: err}
+	}
+
+	_, err = s.storage.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      s.config.GetBucket(),
+		Key:         &archiveKey,
+		Body:        archiveFile,
+		ContentType: aws.String(batchResponse.GetFormat().ContentType()),
+		Metadata: map[string]string{
+			"checksum":  archiveChecksum,
+			"hash":      batchResponse.DownloadHash,
+			"os":        batchResponse.OS,
+			"arch":      batchResponse.Arch,
+			"format":    string(batchResponse.GetFormat()),
+			"plugins":   strconv.Itoa(len(batchResponse.Plugins)),
+			"cache_key": string(batchRequestCacheKey),
+		},
+	})
+	if closeErr := archiveFile.Close(); closeErr != nil {
+		reqLogger.Errorf("could not close plugin archive file: %v", closeErr)
+	}
+	if err != nil {
+		return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not upload plugin archive", Err: err}
+	}
+
+	reqLogger.Infof("uploaded plugin archive.")
+	if s.signer != nil {
+		if err := s.uploadBatchArchiveSignature(ctx, batchResponse, archiveChecksum, false); err != nil {
+			return "", &batchArchiveError{StatusCode: http.StatusInternalServerError, Message: "could not upload plugin archive signature", Err: err}
+		}
+	}
+	if rmErr := os.Remove(archiveFileName); rmErr != nil {
+		reqLogger.Errorf("could not remove plugin archive file: %v", rmErr)
+	}
+	return archiveChecksum, nil
+}
+
+func isAsyncBatchRequest(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// decodeBatchRequest decodes the batch request in the representation of the API version.
+func decodeBatchRequest(r *http.Request) (*registry.BatchRequest, error) {
+	if getAPIVersion(r) == 3 {
+		batchRequest := new(apiv3.BatchRequest)
+		if err := json.NewDecoder(r.Body).Decode(batchRequest); err != nil {
+			return nil, err
+		}
+		return batchRequest.ToRegistry(), nil
+	}
+	batchRequest := new(registry.BatchRequest)
+	if err := json.NewDecoder(r.Body).Decode(batchRequest); err != nil {
+		return nil, err
+	}
+	return batchRequest, nil
+}
+
 func (s *Server) batchGetPlugins(w http.ResponseWriter, r *http.Request) {
 	// limit request body to 1MB
 	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
 
-	batchRequest := new(registry.BatchRequest)
-	if err := json.NewDecoder(r.Body).Decode(batchRequest); err != nil {
+	batchRequest, err := decodeBatchRequest(r)
+	if err != nil {
 		s.writeJSONError(w, r, http.StatusBadRequest, err, "could not decode request")
 		return
 	}
@@ -84,45 +338,28 @@ func (s *Server) batchGetPlugins(w http.ResponseWriter, r *http.Request) {
 
 	reqLogger := s.requestLogger(r)
 	batchResponse := registry.NewBatchResponse(batchRequest, pluginResponses)
+	async := isAsyncBatchRequest(r)
 
 	// hash the batch request without the resolved versions
-	batchRequestCacheKey := s.getCacheKeyWithPrefix(cacheKeyPrefixBatchRequest, hex.EncodeToString(batchResponse.Hash()))
+	batchRequestCacheKey := s.getCacheKeyWithPrefix(cacheKeyPrefixBatchRequest, hex.EncodeToString(batchResponse.RequestHash()))
 	cachedBatchResponse, found := s.getFromCache(r.Context(), batchRequestCacheKey)
 	if found {
 		reqLogger.Infof("found cached batch response for %s", batchRequestCacheKey)
-		s.writeJSON(w, cachedBatchResponse)
+		if async {
+			job := newCompletedBatchJob(cachedBatchResponse.(*registry.BatchResponse), reqLogger)
+			if err := s.batchJobs.add(job); err != nil {
+				s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not save batch job")
+				return
+			}
+			s.writeBatchJob(w, r, job)
+			return
+		}
+		s.writeJSON(w, r, cachedBatchResponse)
 		return
 	}
 
 	// resolve plugins
-	errGroup, groupCtx := errgroup.WithContext(r.Context())
-	errGroup.SetLimit(5)
-	for _, pluginResponse := range batchResponse.Plugins {
-		pluginResponse := pluginResponse
-		errGroup.Go(func() error {
-			p := config.Plugins.Find(pluginResponse.FullName)
-			foundRelease, rErr := p.GetReleaseWithVersionConstraint(groupCtx, s.db, pluginResponse.VersionConstraint)
-			if rErr != nil {
-				return &pluginBatchError{
-					PluginName: pluginResponse.FullName,
-					Err:        rErr,
-				}
-			}
-			foundAsset := foundRelease.Assets[batchResponse.GetOSArch()]
-			if foundAsset == nil {
-				return &pluginBatchError{
-					PluginName: pluginResponse.FullName,
-					Err:        fmt.Errorf("could not find %s asset", batchResponse.GetOSArch()),
-				}
-			}
-			pluginResponse.Version = foundRelease.Version
-			pluginResponse.FileName = foundAsset.FileName
-			pluginResponse.URL = foundAsset.URL
-			pluginResponse.Checksum = foundAsset.Checksum
-			return nil
-		})
-	}
-	err = errGroup.Wait()
+	err = s.resolveBatchResponsePlugins(r.Context(), batchResponse)
 	pbErr := &pluginBatchError{}
 	if errors.As(err, &pbErr) {
 		s.writeJSONError(w, r, http.StatusBadRequest, pbErr, fmt.Sprintf("could not resolve plugin %s", pbErr.PluginName))
@@ -134,79 +371,66 @@ func (s *Server) batchGetPlugins(w http.ResponseWriter, r *http.Request) {
 
 	// calculate the hash of the response, this now includes the plugin versions
 	batchResponse.CalculateHash()
-	archiveKey := fmt.Sprintf("archives/plugins-%s.tar.gz", batchResponse.DownloadHash)
 	// the download url is deterministic, so we can set it here
-	batchResponse.DownloadURL = s.config.GetPublicPluginCacheDownloadURL(archiveKey)
-
-	// allow only one batch archive process at a time
-	err = s.batchArchiveSemaphore.Acquire(r.Context(), 1)
-	if err != nil {
-		s.writeJSONError(w, r, http.StatusTooManyRequests, err, "could not acquire semaphore")
-		return
+	batchResponse.DownloadURL = s.config.GetPublicPluginCacheDownloadURL(getBatchArchiveKey(batchResponse))
+	if s.signer != nil {
+		batchResponse.DownloadSignatureURL = s.config.GetPublicPluginCacheDownloadURL(getBatchArchiveSignatureKey(batchResponse))
 	}
-	defer s.batchArchiveSemaphore.Release(1)
 
-	headRes, err := s.storage.HeadObject(r.Context(), &s3.HeadObjectInput{
-		Bucket: s.config.GetBucket(),
-		Key:    &archiveKey,
-	})
-	if err == nil {
-		// the archive already exists, return the response
-		reqLogger.Infof("found cached archive %s", archiveKey)
-		batchResponse.DownloadChecksum = headRes.Metadata["checksum"]
-		s.setInCache(r.Context(), batchRequestCacheKey, batchResponse)
-		s.writeJSON(w, batchResponse)
+	if async {
+		job := newBatchJob(batchResponse, batchRequestCacheKey, reqLogger)
+		if err := s.batchJobs.submit(job); errors.Is(err, errBatchJobQueueFull) {
+			s.writeJSONError(w, r, http.StatusServiceUnavailable, err, "could not queue batch job")
+			return
+		} else if err != nil {
+			s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not save batch job")
+			return
+		}
+		reqLogger.Infof("queued batch job %s for %s", job.id, batchResponse.DownloadHash)
+		s.writeBatchJob(w, r, job)
 		return
 	}
 
-	var s3ResponseError *awshttp.ResponseError
-	if !errors.As(err, &s3ResponseError) || s3ResponseError.HTTPStatusCode() != http.StatusNotFound {
-		reqLogger.Errorf("could not check if plugin archive exists: %v", err)
-		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not check if plugin archive exists")
+	err = s.createBatchArchive(r.Context(), reqLogger, batchResponse, batchRequestCacheKey)
+	baErr := &batchArchiveError{}
+	if errors.As(err, &baErr) {
+		s.writeJSONError(w, r, baErr.StatusCode, baErr.Err, baErr.Message)
 		return
-	}
-
-	reqLogger.Infof("plugin archive %s not found, creating (%d plugins for %s)...", archiveKey, len(batchResponse.Plugins), batchResponse.GetOSArch())
-	tgzFileName, tgzChecksum, err := batch.DownloadFilesAndTarGz(r.Context(), batchResponse)
-	if err != nil {
+	} else if err != nil {
 		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not create plugin archive")
 		return
 	}
-	batchResponse.DownloadChecksum = tgzChecksum
-	reqLogger.Infof("created plugin archive %s, uploading...", tgzFileName)
-	tarFile, err := os.Open(tgzFileName)
-	if err != nil {
-		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not open plugin archive")
+	s.writeJSON(w, r, batchResponse)
+}
+
+// resolvePluginHandler resolves the asset of a single plugin for the requested platform like the batch endpoint.
+func (s *Server) resolvePluginHandler(w http.ResponseWriter, r *http.Request) {
+	pluginName := chi.URLParam(r, "plugin")
+	if config.Plugins.Find(pluginName) == nil {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("plugin %s not found", pluginName))
 		return
 	}
-
-	_, err = s.storage.PutObject(r.Context(), &s3.PutObjectInput{
-		Bucket:      s.config.GetBucket(),
-		Key:         &archiveKey,
-		Body:        tarFile,
-		ContentType: aws.String("application/gzip"),
-		Metadata: map[string]string{
-			"checksum":  tgzChecksum,
-			"hash":      batchResponse.DownloadHash,
-			"os":        batchResponse.OS,
-			"arch":      batchResponse.Arch,
-			"plugins":   strconv.Itoa(len(batchResponse.Plugins)),
-			"cache_key": string(batchRequestCacheKey),
-		},
-	})
-	if closeErr := tarFile.Close(); closeErr != nil {
-		reqLogger.Errorf("could not close plugin archive file: %v", closeErr)
+	query := r.URL.Query()
+	batchRequest := &registry.BatchRequest{
+		OS:                     query.Get("os"),
+		Arch:                   query.Get("arch"),
+		Variant:                query.Get("variant"),
+		SemanticReleaseVersion: query.Get("semantic_release_version"),
+		Plugins: []*registry.BatchRequestPlugin{{
+			FullName:          pluginName,
+			VersionConstraint: query.Get("constraint"),
+		}},
 	}
+	pluginResponses, err := validateAndCreatePluginResponses(batchRequest)
 	if err != nil {
-		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not upload plugin archive")
+		s.writeJSONError(w, r, http.StatusBadRequest, err)
 		return
 	}
-
-	reqLogger.Infof("uploaded plugin archive.")
-	if rmErr := os.Remove(tgzFileName); rmErr != nil {
-		reqLogger.Errorf("could not remove plugin archive file: %v", rmErr)
+	batchResponse := registry.NewBatchResponse(batchRequest, pluginResponses)
+	_, err = s.resolvePlugin(r.Context(), pluginResponses[0], batchResponse.GetOSArch(), batchResponse.GetSemanticReleaseVersion())
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusBadRequest, err, fmt.Sprintf("could not resolve plugin %s", pluginName))
+		return
 	}
-
-	s.setInCache(r.Context(), batchRequestCacheKey, batchResponse)
-	s.writeJSON(w, batchResponse)
+	s.writeJSON(w, r, pluginResponses[0])
 }
@@ -3,17 +3,54 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/internal/plugin"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func (s *Server) listPlugins(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) listPlugins(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	plugins := config.Plugins.Filter(query.Get("type"), query.Get("q"))
+	if expand, _ := strconv.ParseBool(query.Get("expand")); expand {
+		s.listPluginSummaries(w, r, plugins)
+		return
+	}
 	res := make([]string, 0)
-	for _, p := range config.Plugins {
+	for _, p := range plugins {
 		res = append(res, p.GetFullName())
 	}
-	s.writeJSON(w, res)
+	s.writeJSON(w, r, res)
+}
+
+func (s *Server) listPluginSummaries(w http.ResponseWriter, r *http.Request, plugins plugin.Plugins) {
+	res := make([]*registry.Plugin, len(plugins))
+	errGroup, groupCtx := errgroup.WithContext(r.Context())
+	errGroup.SetLimit(5)
+	for i, p := range plugins {
+		errGroup.Go(func() error {
+			summary, err := p.GetSummary(groupCtx, s.db)
+			if err != nil {
+				return err
+			}
+			res[i] = summary
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get plugins")
+		return
+	}
+
+	s.cacheResponse(r, res)
+	s.writeJSON(w, r, res)
 }
 
 func (s *Server) updateAllPlugins(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +65,7 @@ func (s *Server) updateAllPlugins(w http.ResponseWriter, r *http.Request) {
 	reqLogger.Warn("updating all plugins...")
 	for _, p := range config.Plugins {
 		reqLogger.Infof("updating plugin %s", p.GetFullName())
-		err := p.Update(r.Context(), s.db, s.ghClient, "")
+		err := p.Update(r.Context(), reqLogger, s.db, s.ghClient, "")
 		if err != nil {
 			s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not update plugin")
 			return
@@ -36,7 +73,9 @@ func (s *Server) updateAllPlugins(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.invalidateByPrefix(s.getCacheKeyPrefixFromPluginName(""))
-	s.writeJSON(w, map[string]bool{"ok": true})
+	s.invalidateByPrefix(s.getPlatformMatrixCacheKey())
+	s.invalidatePluginListCache()
+	s.writeJSON(w, r, map[string]bool{"ok": true})
 }
 
 func (s *Server) updatePlugin(w http.ResponseWriter, r *http.Request) {
@@ -61,13 +100,15 @@ func (s *Server) updatePlugin(w http.ResponseWriter, r *http.Request) {
 	}
 	defer s.ghSemaphore.Release(1)
 
-	if err := p.Update(r.Context(), s.db, s.ghClient, pluginVersion); err != nil {
+	if err := p.Update(r.Context(), reqLogger, s.db, s.ghClient, pluginVersion); err != nil {
 		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not update plugin")
 		return
 	}
 
 	s.invalidateByPrefix(s.getCacheKeyPrefixFromPluginName(p.GetFullName()))
-	s.writeJSON(w, map[string]bool{"ok": true})
+	s.invalidateByPrefix(s.getPlatformMatrixCacheKey())
+	s.invalidatePluginListCache()
+	s.writeJSON(w, r, map[string]bool{"ok": true})
 }
 
 func (s *Server) getPlugin(w http.ResponseWriter, r *http.Request) {
@@ -95,8 +136,8 @@ func (s *Server) getPlugin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.setInCache(r.Context(), s.getCacheKeyFromRequest(r), res)
-	s.writeJSON(w, res)
+	s.cacheResponse(r, res)
+	s.writeJSON(w, r, res)
 }
 
 func (s *Server) listPluginVersions(w http.ResponseWriter, r *http.Request) {
@@ -113,6 +154,132 @@ func (s *Server) listPluginVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.setInCache(r.Context(), s.getCacheKeyFromRequest(r), versions)
-	s.writeJSON(w, versions)
+	s.cacheResponse(r, versions)
+	s.writeJSON(w, r, versions)
+}
+
+func (s *Server) listPublicKeys(w http.ResponseWriter, r *http.Request) {
+	res := make([]*registry.PublicKey, 0)
+	if s.signer != nil {
+		res = append(res, s.signer.PublicKey())
+	}
+	s.writeJSON(w, r, res)
+}
+
+func (s *Server) listPluginPlatforms(w http.ResponseWriter, r *http.Request) {
+	pluginName := chi.URLParam(r, "plugin")
+	p := config.Plugins.Find(pluginName)
+	if p == nil {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("plugin %s not found", pluginName))
+		return
+	}
+
+	platforms, err := p.GetPlatforms(r.Context(), s.db)
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get plugin platforms")
+		return
+	}
+
+	s.cacheResponse(r, platforms)
+	s.writeJSON(w, r, platforms)
+}
+
+func (s *Server) getPlatformMatrix(w http.ResponseWriter, r *http.Request) {
+	var mu sync.Mutex
+	latestReleases := make(map[string]*registry.PluginRelease, len(config.Plugins))
+	errGroup, groupCtx := errgroup.WithContext(r.Context())
+	errGroup.SetLimit(5)
+	for _, p := range config.Plugins {
+		errGroup.Go(func() error {
+			latestRelease, err := p.GetReleaseWithVersionConstraint(groupCtx, s.db, "latest", nil)
+			// plugins without any release are listed without platforms
+			if err != nil && status.Code(err) != codes.NotFound {
+				return fmt.Errorf("could not get latest release of %s: %w", p.GetFullName(), err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			latestReleases[p.GetFullName()] = latestRelease
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get platform matrix")
+		return
+	}
+
+	matrix := registry.NewPlatformMatrix(latestReleases)
+	s.cacheResponse(r, matrix)
+	s.writeJSON(w, r, matrix)
+}
+
+func (s *Server) getPluginReleaseNotes(w http.ResponseWriter, r *http.Request) {
+	pluginName := chi.URLParam(r, "plugin")
+	p := config.Plugins.Find(pluginName)
+	if p == nil {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("plugin %s not found", pluginName))
+		return
+	}
+
+	pluginVersion := chi.URLParam(r, "version")
+	release, err := p.GetRelease(r.Context(), s.db, pluginVersion)
+	if status.Code(err) == codes.NotFound {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("version %s of plugin %s not found", pluginVersion, pluginName))
+		return
+	}
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get plugin release")
+		return
+	}
+
+	notes := release.GetReleaseNotes()
+	s.cacheResponse(r, notes)
+	s.writeJSON(w, r, notes)
+}
+
+// parseVersionQueryParam parses the version of the query parameter. It returns nil if the parameter is not set.
+func parseVersionQueryParam(r *http.Request, name string) (*semver.Version, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	v, err := semver.NewVersion(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s version %s: %w", name, value, err)
+	}
+	return v, nil
+}
+
+func (s *Server) listPluginReleaseNotes(w http.ResponseWriter, r *http.Request) {
+	pluginName := chi.URLParam(r, "plugin")
+	p := config.Plugins.Find(pluginName)
+	if p == nil {
+		s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("plugin %s not found", pluginName))
+		return
+	}
+
+	from, err := parseVersionQueryParam(r, "from")
+	if err == nil && from == nil {
+		err = fmt.Errorf("from version is missing")
+	}
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	to, err := parseVersionQueryParam(r, "to")
+	if err == nil && to != nil && to.LessThan(from) {
+		err = fmt.Errorf("to version %s is lower than from version %s", to, from)
+	}
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	notes, err := p.GetReleaseNotes(r.Context(), s.db, from, to)
+	if err != nil {
+		s.writeJSONError(w, r, http.StatusInternalServerError, err, "could not get release notes")
+		return
+	}
+
+	s.cacheResponse(r, notes)
+	s.writeJSON(w, r, notes)
 }
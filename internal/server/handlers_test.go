@@ -3,6 +3,8 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -22,6 +24,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/internal/signing"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/google/go-github/v59/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
@@ -88,6 +92,14 @@ func newGitHubClient() *github.Client {
 	return github.NewClient(mockedHTTPClient)
 }
 
+// skipWithoutFirebaseEmulator skips a test that needs the emulator if the gcloud CLI is not installed. The CI
+// installs the emulator, so the test is only skipped in local runs.
+func skipWithoutFirebaseEmulator(t *testing.T) {
+	if _, err := exec.LookPath("gcloud"); err != nil {
+		t.Skip("the Firestore emulator requires the gcloud CLI")
+	}
+}
+
 // adapted from https://www.captaincodeman.com/unit-testing-with-firestore-emulator-and-go
 func starsFirebaseEmulator() (func(), error) {
 	cmd := exec.Command("gcloud", "emulators", "firestore", "start", "--host-port=127.0.0.1:9090")
@@ -112,7 +124,7 @@ func starsFirebaseEmulator() (func(), error) {
 
 func createS3Client(t *testing.T) (*s3.Client, func()) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/test/archives/plugins-") {
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/test/archives/v2/plugins-") {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
@@ -143,7 +155,7 @@ func newTestServer(t *testing.T) (*Server, *firestore.Client, func()) {
 		AdminAccessToken:    "admin-token",
 		CloudflareR2Bucket:  "test",
 		DisableRequestCache: true,
-	}), fsClient, closeFn
+	}, nil), fsClient, closeFn
 }
 
 func sendRequest(s http.Handler, method, path string, body io.Reader, modReqFns ...func(req *http.Request)) *httptest.ResponseRecorder {
@@ -165,6 +177,148 @@ func TestListPlugins(t *testing.T) {
 	var plugins []string
 	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &plugins))
 	require.Len(t, plugins, len(config.Plugins))
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins?type=provider&q=GitLab", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &plugins))
+	require.Equal(t, []string{"provider-gitlab"}, plugins)
+}
+
+func TestCacheKeyContainsQuery(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	newRequest := func(target string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, target, nil)
+	}
+	require.Equal(t, cacheKey("request/GET:/api/v2/plugins"), s.getCacheKeyFromRequest(newRequest("/api/v2/plugins")))
+	require.Equal(t,
+		s.getCacheKeyFromRequest(newRequest("/api/v2/plugins?expand=true&type=provider")),
+		s.getCacheKeyFromRequest(newRequest("/api/v2/plugins?type=provider&expand=true")),
+	)
+	require.NotEqual(t,
+		s.getCacheKeyFromRequest(newRequest("/api/v2/plugins?expand=true&type=provider")),
+		s.getCacheKeyFromRequest(newRequest("/api/v2/plugins?expand=true&type=hooks")),
+	)
+	// the API versions share the cache entries
+	require.Equal(t,
+		s.getCacheKeyFromRequest(newRequest("/api/v2/plugins/provider-git")),
+		s.getCacheKeyFromRequest(newRequest("/api/v3/plugins/provider-git")),
+	)
+}
+
+func TestListPublicKeys(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	rr := sendRequest(s, "GET", "/api/v2/keys", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var keys []*registry.PublicKey
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &keys))
+	require.Empty(t, keys)
+
+	s.signer, _ = signing.New(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	rr = sendRequest(s, "GET", "/api/v2/keys", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &keys))
+	require.Equal(t, []*registry.PublicKey{s.signer.PublicKey()}, keys)
+}
+
+func TestAPIV3(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	rr := sendRequest(s, "GET", "/api/v3/plugins?type=provider&q=GitLab", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var plugins []string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &plugins))
+	require.Equal(t, []string{"provider-gitlab"}, plugins)
+
+	s.signer, _ = signing.New(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	rr = sendRequest(s, "GET", "/api/v3/keys", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var keys []map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &keys))
+	require.Len(t, keys, 1)
+	require.Equal(t, s.signer.PublicKey().KeyID, keys[0]["keyId"])
+
+	rr = sendRequest(s, "GET", "/api/v3/schema.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &schema))
+	require.Equal(t, apiv3.SchemaID, schema["$id"])
+	require.Contains(t, schema["$defs"], "BatchResponse")
+
+	// the batch request is decoded from its v3 representation
+	rr = sendRequest(s, "POST", "/api/v3/plugins/_batch", bytes.NewBufferString(`{"os": "darwin", "arch": "amd64", "plugins": [{"fullName": "wrong"}]}`))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, decodeError(t, rr.Body.Bytes()), "plugin wrong has an invalid name")
+
+	rr = sendRequest(s, "GET", "/api/v3/plugins/provider-unknown/platforms", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+	s.signer, _ = signing.New(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+
+	rr := sendRequest(s, "GET", "/api/v2/keys", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, cacheControlStaticData, rr.Header().Get("Cache-Control"))
+	etag := rr.Header().Get("ETag")
+	require.Equal(t, computeETag(rr.Body.Bytes()), etag)
+
+	rr = sendRequest(s, "GET", "/api/v2/keys", nil, func(req *http.Request) {
+		req.Header.Set("If-None-Match", `"other", W/`+etag)
+	})
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Empty(t, rr.Body.Bytes())
+	require.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// the representations of the API versions have different entity tags
+	rr = sendRequest(s, "GET", "/api/v3/keys", nil, func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+	})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins?type=provider", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, cacheControlPluginData, rr.Header().Get("Cache-Control"))
+
+	// error responses do not have validators
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-unknown", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Empty(t, rr.Header().Get("ETag"))
+
+	// the test does not use the emulator
+	s.batchJobs.store = newMemoryBatchJobStore()
+	rr = sendRequest(s, "GET", "/api/v2/plugins/_batch/jobs/unknown", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Equal(t, cacheControlNoStore, rr.Header().Get("Cache-Control"))
+	require.Empty(t, rr.Header().Get("ETag"))
+}
+
+func TestIsNotModified(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastModifiedHeader := updatedAt.Format(http.TimeFormat)
+	newRequest := func(header, value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/plugins/provider-git", nil)
+		req.Header.Set(header, value)
+		return req
+	}
+	require.True(t, isNotModified(newRequest("If-Modified-Since", lastModifiedHeader), `"a"`, lastModifiedHeader))
+	require.True(t, isNotModified(newRequest("If-Modified-Since", updatedAt.Add(time.Hour).Format(http.TimeFormat)), `"a"`, lastModifiedHeader))
+	require.False(t, isNotModified(newRequest("If-Modified-Since", updatedAt.Add(-time.Hour).Format(http.TimeFormat)), `"a"`, lastModifiedHeader))
+	require.False(t, isNotModified(newRequest("If-Modified-Since", lastModifiedHeader), `"a"`, ""))
+	require.True(t, isNotModified(newRequest("If-None-Match", "*"), `"a"`, ""))
+	require.False(t, isNotModified(newRequest("If-None-Match", `"b"`), `"a"`, lastModifiedHeader))
+
+	plugin := &registry.Plugin{UpdatedAt: updatedAt, LatestRelease: &registry.PluginRelease{UpdatedAt: updatedAt.Add(time.Hour)}}
+	require.Equal(t, updatedAt.Add(time.Hour), lastModified(plugin))
+	require.Equal(t, updatedAt.Add(time.Hour), lastModified([]*registry.Plugin{{UpdatedAt: updatedAt}, plugin}))
+	require.True(t, lastModified(map[string]string{"error": "not found"}).IsZero())
 }
 
 func saveDoc(fsClient *firestore.Client, collection, doc string, data map[string]any) error {
@@ -188,10 +342,17 @@ func createPluginDoc(fsClient *firestore.Client, dlHost, fullName, latestRelease
 	versionsCollection := fmt.Sprintf("dev-plugins/%s/versions", fullName)
 
 	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0", "3.0.0", latestRelease} {
+		semRelConstraint := ""
+		if version == "3.0.0" {
+			semRelConstraint = ">= 2.20.0"
+		}
 		err = saveDoc(fsClient, versionsCollection, version, map[string]any{
-			"Version":    version,
-			"Prerelease": false,
-			"CreatedAt":  time.Now(),
+			"SemanticReleaseConstraint": semRelConstraint,
+			"Version":                   version,
+			"Prerelease":                false,
+			"CreatedAt":                 time.Now(),
+			"HTMLURL":                   fmt.Sprintf("https://github.com/my-org/%s/releases/tag/v%s", fullName, version),
+			"ReleaseNotes":              "notes " + version,
 			"Assets": map[string]map[string]string{
 				"darwin/amd64": {
 					"FileName": fullName + "-darwin-amd64",
@@ -254,6 +415,21 @@ func TestGetPlugin(t *testing.T) {
 	require.Len(t, plugin.Versions, 5)
 	require.Equal(t, "3.0.0", plugin.LatestRelease.Version)
 	require.Equal(t, "provider-git-darwin-amd64", plugin.LatestRelease.Assets["darwin/amd64"].FileName)
+	lastModifiedHeader := rr.Header().Get("Last-Modified")
+	require.Equal(t, lastModified(&plugin).Format(http.TimeFormat), lastModifiedHeader)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git", nil, func(req *http.Request) {
+		req.Header.Set("If-Modified-Since", lastModifiedHeader)
+	})
+	require.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = sendRequest(s, "GET", "/api/v3/plugins/provider-git", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var pluginV3 apiv3.Plugin
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginV3))
+	require.Contains(t, rr.Body.String(), `"fullName":"provider-git"`)
+	require.Equal(t, "3.0.0", pluginV3.LatestRelease.Version)
+	require.Equal(t, "sha256", pluginV3.LatestRelease.Assets["darwin/amd64"].ChecksumAlgorithm)
 }
 
 func TestGetPluginVersions(t *testing.T) {
@@ -350,6 +526,136 @@ func TestBatchEndpoint(t *testing.T) {
 	require.Equal(t, "925aa24645bce75b089b973df930de01698242203695fe418a8020fc9d997a4f", batchResponse.DownloadHash)
 }
 
+func TestPlatformEndpoints(t *testing.T) {
+	killFirebaseEmulator, err := starsFirebaseEmulator()
+	require.NoError(t, err)
+	defer killFirebaseEmulator()
+	s, fsClient, closeFn := newTestServer(t)
+	defer closeFn()
+
+	dlServerCloseFn := bootstrapDatabase(t, fsClient)
+	defer dlServerCloseFn()
+
+	rr := sendRequest(s, "GET", "/api/v2/plugins/provider-git/platforms", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var pluginPlatforms registry.PluginPlatforms
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginPlatforms))
+	require.Equal(t, "3.0.0", pluginPlatforms.LatestVersion)
+	require.Len(t, pluginPlatforms.Platforms, 2)
+	require.Equal(t, "darwin/amd64", pluginPlatforms.Platforms[0].Platform)
+	require.Equal(t, []string{"3.0.0", "2.0.0", "1.2.0", "1.1.0", "1.0.0"}, pluginPlatforms.Platforms[0].Versions)
+	require.True(t, pluginPlatforms.Platforms[0].Latest)
+
+	rr = sendRequest(s, "GET", "/api/v2/platforms", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var matrix registry.PlatformMatrix
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &matrix))
+	require.Equal(t, []string{"darwin/amd64", "linux/amd64"}, matrix.Platforms)
+	require.Equal(t, []string{"darwin/amd64", "linux/amd64"}, matrix.Plugins["hooks-goreleaser"])
+	require.Len(t, matrix.Plugins, len(config.Plugins))
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-unknown/platforms", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestReleaseNotesEndpoints(t *testing.T) {
+	killFirebaseEmulator, err := starsFirebaseEmulator()
+	require.NoError(t, err)
+	defer killFirebaseEmulator()
+	s, fsClient, closeFn := newTestServer(t)
+	defer closeFn()
+
+	dlServerCloseFn := bootstrapDatabase(t, fsClient)
+	defer dlServerCloseFn()
+
+	rr := sendRequest(s, "GET", "/api/v2/plugins/provider-git/versions/1.1.0/notes", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var notes registry.ReleaseNotes
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &notes))
+	require.Equal(t, "notes 1.1.0", notes.Notes)
+	require.Equal(t, "https://github.com/my-org/provider-git/releases/tag/v1.1.0", notes.HTMLURL)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/versions/9.9.9/notes", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/notes?from=1.1.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var notesRange registry.ReleaseNotesRange
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &notesRange))
+	require.Equal(t, "3.0.0", notesRange.To)
+	require.Len(t, notesRange.Releases, 3)
+	require.Equal(t, "notes 3.0.0", notesRange.Releases[0].Notes)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/notes?from=1.0.0&to=1.2.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &notesRange))
+	require.Len(t, notesRange.Releases, 2)
+}
+
+func TestReleaseNotesBadRequests(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	for _, query := range []string{"", "?from=invalid", "?from=1.0.0&to=invalid", "?from=2.0.0&to=1.0.0"} {
+		rr := sendRequest(s, "GET", "/api/v2/plugins/provider-git/notes"+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+	rr := sendRequest(s, "GET", "/api/v2/plugins/provider-unknown/notes?from=1.0.0", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestResolvePlugin(t *testing.T) {
+	killFirebaseEmulator, err := starsFirebaseEmulator()
+	require.NoError(t, err)
+	defer killFirebaseEmulator()
+	s, fsClient, closeFn := newTestServer(t)
+	defer closeFn()
+
+	dlServerCloseFn := bootstrapDatabase(t, fsClient)
+	defer dlServerCloseFn()
+
+	rr := sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=amd64&constraint=^1.0.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var pluginResponse registry.BatchResponsePlugin
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginResponse))
+	require.Equal(t, "1.2.0", pluginResponse.Version)
+	require.Equal(t, "linux/amd64", pluginResponse.Platform)
+
+	// Apple silicon falls back to darwin/amd64
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=darwin&arch=arm64", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginResponse))
+	require.Equal(t, "3.0.0", pluginResponse.Version)
+	require.Equal(t, "provider-git-darwin-amd64", pluginResponse.FileName)
+	require.Equal(t, "darwin/amd64", pluginResponse.Platform)
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=arm64", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, decodeError(t, rr.Body.Bytes()), "could not resolve plugin provider-git")
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, decodeError(t, rr.Body.Bytes()), "os and arch are required")
+
+	// the latest release requires semantic-release >= 2.20.0
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=amd64&semantic_release_version=2.19.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginResponse))
+	require.Equal(t, "2.0.0", pluginResponse.Version)
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=amd64&semantic_release_version=2.20.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pluginResponse))
+	require.Equal(t, "3.0.0", pluginResponse.Version)
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=amd64&constraint=^3.0.0&semantic_release_version=2.19.0", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-git/resolve?os=linux&arch=amd64&semantic_release_version=invalid", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, decodeError(t, rr.Body.Bytes()), "invalid semantic-release version")
+
+	rr = sendRequest(s, "GET", "/api/v2/plugins/provider-unknown/resolve?os=linux&arch=amd64", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
 func decodeError(t *testing.T, body []byte) string {
 	var err struct {
 		Error string `json:"error"`
@@ -1,12 +1,16 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 	"github.com/sirupsen/logrus"
 )
 
@@ -14,7 +18,59 @@ func (s *Server) setContentTypeJSON(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 }
 
-func (s *Server) writeJSON(w http.ResponseWriter, d any) {
+type apiVersionKey struct{}
+
+// withAPIVersion sets the API version of the requests, which determines the representation of the responses.
+func withAPIVersion(version int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+// getAPIVersion returns the API version of the request, requests outside of the versioned APIs use version 2.
+func getAPIVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return 2
+}
+
+// lastModified returns the time of the last update of the response data or the zero time if it is unknown.
+func lastModified(d any) time.Time {
+	switch d := d.(type) {
+	case *registry.PluginRelease:
+		if d != nil {
+			return d.UpdatedAt
+		}
+	case *registry.Plugin:
+		// the plugin document is updated whenever its versions change, so its update time covers the Versions
+		if d != nil && d.LatestRelease != nil && d.LatestRelease.UpdatedAt.After(d.UpdatedAt) {
+			return d.LatestRelease.UpdatedAt
+		}
+		if d != nil {
+			return d.UpdatedAt
+		}
+	case []*registry.Plugin:
+		var latest time.Time
+		for _, p := range d {
+			if t := lastModified(p); t.After(latest) {
+				latest = t
+			}
+		}
+		return latest
+	}
+	return time.Time{}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, d any) {
+	if t := lastModified(d); !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+	if getAPIVersion(r) == 3 {
+		d = apiv3.Convert(d)
+	}
 	s.setContentTypeJSON(w)
 	err := json.NewEncoder(w).Encode(d)
 	if err != nil {
@@ -32,7 +88,7 @@ func (s *Server) writeJSONError(w http.ResponseWriter, r *http.Request, statusCo
 	if len(alternativeMessage) > 0 {
 		errMsg = strings.Join(alternativeMessage, " ")
 	}
-	s.writeJSON(w, map[string]string{"error": errMsg})
+	s.writeJSON(w, r, map[string]string{"error": errMsg})
 }
 
 func (s *Server) requestLogger(r *http.Request) *logrus.Entry {
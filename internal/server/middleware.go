@@ -1,8 +1,12 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
@@ -37,3 +41,100 @@ func (s *Server) recoverMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// cacheControlMiddleware sets the Cache-Control header of the responses.
+func cacheControlMiddleware(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bufferedResponseWriter buffers the response body, the headers are written to the underlying response writer.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if bw.statusCode == 0 {
+		bw.statusCode = statusCode
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedResponseWriter) flush() {
+	if bw.statusCode == 0 {
+		bw.statusCode = http.StatusOK
+	}
+	bw.ResponseWriter.WriteHeader(bw.statusCode)
+	_, _ = bw.ResponseWriter.Write(bw.body.Bytes())
+}
+
+func computeETag(body []byte) string {
+	h := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+// etagMatches reports whether the ETag matches one of the entity tags of the If-None-Match header
+// using the weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotModified evaluates the conditional headers of the request, If-Modified-Since is ignored if If-None-Match is set.
+func isNotModified(r *http.Request, etag, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified == "" {
+		return false
+	}
+	modifiedAt, err := http.ParseTime(lastModified)
+	return err == nil && !modifiedAt.After(ifModifiedSince)
+}
+
+// conditionalMiddleware sets the ETag of successful GET responses to the hash of the payload and answers
+// conditional requests with 304 Not Modified if the response did not change.
+func (s *Server) conditionalMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r)
+		if bw.statusCode != 0 && bw.statusCode != http.StatusOK {
+			bw.flush()
+			return
+		}
+		// cached responses already have an entity tag
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			etag = computeETag(bw.body.Bytes())
+			w.Header().Set("ETag", etag)
+		}
+		if isNotModified(r, etag, w.Header().Get("Last-Modified")) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Encoding")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		bw.flush()
+	})
+}
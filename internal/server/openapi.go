@@ -0,0 +1,394 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-semantic-release/plugin-registry/internal/jsonschema"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+// openAPIParameter is a query parameter of an operation, path parameters are derived from the path.
+type openAPIParameter struct {
+	name        string
+	description string
+	required    bool
+	schema      map[string]any
+}
+
+// openAPIOperation describes a route of the registry.
+type openAPIOperation struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	parameters  []openAPIParameter
+	// requestBody is the schema of the JSON request body.
+	requestBody map[string]any
+	// responses maps the status codes to the schemas of the JSON response bodies, a nil schema
+	// describes an error response.
+	responses map[int]map[string]any
+	admin     bool
+	// conditional reports whether the responses have an ETag and conditional requests are answered with 304.
+	conditional bool
+	// rootPath reports whether the route is served outside of the API prefix.
+	rootPath bool
+	// v3Only reports whether the route is only served by the v3 API.
+	v3Only bool
+}
+
+var openAPIPathParameters = map[string]string{
+	"plugin":  "Full name (or alias) of the plugin, e.g. provider-github.",
+	"version": "Version of the plugin release.",
+	"id":      "ID of the batch job.",
+	"os":      "Operating system of the binary, e.g. linux.",
+	"arch":    "Architecture of the binary, e.g. amd64.",
+}
+
+var openAPIPathParameterRegexp = regexp.MustCompile(`\{([^}]+)}`)
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items map[string]any) map[string]any {
+	return map[string]any{"type": "array", "items": items}
+}
+
+var (
+	stringSchema  = map[string]any{"type": "string"}
+	booleanSchema = map[string]any{"type": "boolean"}
+	okSchema      = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"ok": booleanSchema},
+		"required":   []string{"ok"},
+	}
+)
+
+// openAPIOperations are all routes of the registry, the /api/v2 and /api/v3 routes share the same paths and schema names.
+var openAPIOperations = []openAPIOperation{
+	{
+		method: http.MethodGet, path: "/schema.json", operationID: "getSchema", conditional: true, v3Only: true,
+		summary:   "Returns the JSON Schema of the v3 API types.",
+		responses: map[int]map[string]any{http.StatusOK: {"type": "object"}},
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", operationID: "getOpenAPIDocument", conditional: true,
+		summary:   "Returns this OpenAPI document.",
+		responses: map[int]map[string]any{http.StatusOK: {"type": "object"}},
+	},
+	{
+		method: http.MethodGet, path: "/keys", operationID: "listPublicKeys", conditional: true,
+		summary:   "Lists the public keys that are used to sign batch responses and archives.",
+		responses: map[int]map[string]any{http.StatusOK: arrayOf(schemaRef("PublicKey"))},
+	},
+	{
+		method: http.MethodGet, path: "/platforms", operationID: "getPlatformMatrix", conditional: true,
+		summary: "Lists the platforms that are supported by the latest releases of all plugins.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("PlatformMatrix"),
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins", operationID: "listPlugins", conditional: true,
+		summary: "Lists the names of all plugins or, with expand=true, their summaries.",
+		parameters: []openAPIParameter{
+			{name: "type", description: "Only list plugins of this type, e.g. provider.", schema: stringSchema},
+			{name: "q", description: "Only list plugins whose names, aliases, descriptions or keywords contain the query.", schema: stringSchema},
+			{name: "expand", description: "List the plugin summaries instead of the names.", schema: booleanSchema},
+		},
+		responses: map[int]map[string]any{
+			http.StatusOK:                  {"oneOf": []any{arrayOf(stringSchema), arrayOf(schemaRef("Plugin"))}},
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}", operationID: "getPlugin", conditional: true,
+		summary: "Returns the plugin including its latest release and all versions.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("Plugin"),
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/versions", operationID: "listPluginVersions", conditional: true,
+		summary: "Lists all versions of the plugin.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  arrayOf(stringSchema),
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/platforms", operationID: "listPluginPlatforms", conditional: true,
+		summary: "Lists the platforms that are supported by the releases of the plugin.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("PluginPlatforms"),
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/versions/{version}", operationID: "getPluginRelease", conditional: true,
+		summary: "Returns a release of the plugin.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("PluginRelease"),
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/versions/{version}/notes", operationID: "getPluginReleaseNotes", conditional: true,
+		summary: "Returns the release notes of a release of the plugin.",
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("ReleaseNotes"),
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/notes", operationID: "listPluginReleaseNotes", conditional: true,
+		summary: "Returns the release notes of all releases after the from version up to the to version.",
+		parameters: []openAPIParameter{
+			{name: "from", description: "Exclusive lower bound of the versions.", required: true, schema: stringSchema},
+			{name: "to", description: "Inclusive upper bound of the versions, defaults to the latest version.", schema: stringSchema},
+		},
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("ReleaseNotesRange"),
+			http.StatusBadRequest:          nil,
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/{plugin}/resolve", operationID: "resolvePlugin", conditional: true,
+		summary: "Resolves the asset of the plugin for a platform like the batch endpoint.",
+		parameters: []openAPIParameter{
+			{name: "os", description: "Operating system of the asset.", required: true, schema: stringSchema},
+			{name: "arch", description: "Architecture of the asset.", required: true, schema: stringSchema},
+			{name: "variant", description: "ARM variant of the asset.", schema: map[string]any{"type": "string", "enum": []string{"v5", "v6", "v7"}}},
+			{name: "constraint", description: "Version constraint of the release, defaults to the latest release.", schema: stringSchema},
+			{name: "semantic_release_version", description: "Only resolve releases that are compatible with this semantic-release version.", schema: stringSchema},
+		},
+		responses: map[int]map[string]any{
+			http.StatusOK:         schemaRef("BatchResponsePlugin"),
+			http.StatusBadRequest: nil,
+			http.StatusNotFound:   nil,
+		},
+	},
+	{
+		method: http.MethodPost, path: "/plugins/_batch", operationID: "batchGetPlugins",
+		summary: "Resolves multiple plugins and bundles their assets in a single archive.",
+		parameters: []openAPIParameter{
+			{name: "async", description: "Create the archive in a background job.", schema: booleanSchema},
+		},
+		requestBody: schemaRef("BatchRequest"),
+		responses: map[int]map[string]any{
+			http.StatusOK:                  schemaRef("BatchResponse"),
+			http.StatusAccepted:            schemaRef("BatchJob"),
+			http.StatusBadRequest:          nil,
+			http.StatusTooManyRequests:     nil,
+			http.StatusInternalServerError: nil,
+			http.StatusServiceUnavailable:  nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/plugins/_batch/jobs/{id}", operationID: "getBatchJob",
+		summary: "Returns the status of a batch job.",
+		responses: map[int]map[string]any{
+			http.StatusOK:       schemaRef("BatchJob"),
+			http.StatusNotFound: nil,
+		},
+	},
+	{
+		method: http.MethodPut, path: "/plugins", operationID: "updateAllPlugins",
+		summary: "Updates all plugins from their GitHub releases.",
+		admin:   true,
+		responses: map[int]map[string]any{
+			http.StatusOK:                  okSchema,
+			http.StatusUnauthorized:        nil,
+			http.StatusTooManyRequests:     nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodPut, path: "/plugins/{plugin}", operationID: "updatePlugin",
+		summary: "Updates the plugin from its GitHub releases.",
+		admin:   true,
+		responses: map[int]map[string]any{
+			http.StatusOK:                  okSchema,
+			http.StatusUnauthorized:        nil,
+			http.StatusNotFound:            nil,
+			http.StatusTooManyRequests:     nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodPut, path: "/plugins/{plugin}/versions/{version}", operationID: "updatePluginRelease",
+		summary: "Updates a release of the plugin from its GitHub release.",
+		admin:   true,
+		responses: map[int]map[string]any{
+			http.StatusOK:                  okSchema,
+			http.StatusUnauthorized:        nil,
+			http.StatusNotFound:            nil,
+			http.StatusTooManyRequests:     nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+	{
+		method: http.MethodDelete, path: "/plugins/_cache", operationID: "invalidateCache",
+		summary: "Invalidates the cached responses with the given key prefix.",
+		admin:   true,
+		parameters: []openAPIParameter{
+			{name: "prefix", description: "Prefix of the cache keys.", schema: stringSchema},
+		},
+		responses: map[int]map[string]any{
+			http.StatusOK: {
+				"type": "object",
+				"properties": map[string]any{
+					"prefix":  stringSchema,
+					"deleted": map[string]any{"type": "integer"},
+				},
+				"required": []string{"prefix", "deleted"},
+			},
+			http.StatusUnauthorized: nil,
+		},
+	},
+	{
+		method: http.MethodGet, path: "/downloads/{os}/{arch}/semantic-release", operationID: "downloadSemanticRelease",
+		summary:  "Redirects to the latest semantic-release binary for the platform.",
+		rootPath: true,
+		responses: map[int]map[string]any{
+			http.StatusFound:               nil,
+			http.StatusNotFound:            nil,
+			http.StatusInternalServerError: nil,
+		},
+	},
+}
+
+func (o *openAPIOperation) document() map[string]any {
+	parameters := make([]any, 0)
+	for _, match := range openAPIPathParameterRegexp.FindAllStringSubmatch(o.path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name":        match[1],
+			"in":          "path",
+			"description": openAPIPathParameters[match[1]],
+			"required":    true,
+			"schema":      stringSchema,
+		})
+	}
+	for _, p := range o.parameters {
+		parameters = append(parameters, map[string]any{
+			"name":        p.name,
+			"in":          "query",
+			"description": p.description,
+			"required":    p.required,
+			"schema":      p.schema,
+		})
+	}
+
+	responses := make(map[string]any, len(o.responses))
+	for statusCode, schema := range o.responses {
+		response := map[string]any{"description": http.StatusText(statusCode)}
+		switch {
+		case statusCode == http.StatusFound:
+			response["headers"] = map[string]any{"Location": map[string]any{"schema": stringSchema}}
+		case schema == nil:
+			schema = schemaRef("Error")
+			fallthrough
+		default:
+			response["content"] = map[string]any{"application/json": map[string]any{"schema": schema}}
+		}
+		responses[strconv.Itoa(statusCode)] = response
+	}
+	if o.conditional {
+		responses[strconv.Itoa(http.StatusOK)].(map[string]any)["headers"] = map[string]any{
+			"ETag":          map[string]any{"schema": stringSchema},
+			"Last-Modified": map[string]any{"schema": stringSchema},
+			"Cache-Control": map[string]any{"schema": stringSchema},
+		}
+		responses[strconv.Itoa(http.StatusNotModified)] = map[string]any{"description": http.StatusText(http.StatusNotModified)}
+	}
+
+	operation := map[string]any{
+		"operationId": o.operationID,
+		"summary":     o.summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+	if o.requestBody != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": o.requestBody}},
+		}
+	}
+	if o.admin {
+		operation["security"] = []any{map[string]any{"adminAccessToken": []string{}}}
+	}
+	return operation
+}
+
+// openAPIV2Types are the registry types that are served by the v2 API with their Go field names.
+var openAPIV2Types = []any{
+	registry.Plugin{}, registry.PluginRelease{}, registry.ReleaseNotes{}, registry.ReleaseNotesRange{},
+	registry.PluginPlatforms{}, registry.PlatformMatrix{}, registry.BatchRequest{}, registry.BatchResponse{},
+	registry.BatchResponsePlugin{}, registry.BatchJob{}, registry.PublicKey{},
+}
+
+// openAPISchemas returns the component schemas of the API version.
+func openAPISchemas(apiVersion int) map[string]any {
+	if apiVersion == 3 {
+		return apiv3.Definitions("#/components/schemas/")
+	}
+	schemas := jsonschema.Definitions(openAPIV2Types, "#/components/schemas/", jsonschema.FieldNames)
+	// errors are written with the same representation in both versions
+	schemas["Error"] = apiv3.Definitions("#/components/schemas/")["Error"]
+	return schemas
+}
+
+// openAPIDocument returns the OpenAPI document of the API version. The schemas of the v3 API describe the camelCase
+// representation, the schemas of the v2 API the Go field names of the registry package.
+func (s *Server) openAPIDocument(apiVersion int) map[string]any {
+	paths := make(map[string]any)
+	for i := range openAPIOperations {
+		o := &openAPIOperations[i]
+		if o.v3Only && apiVersion != 3 {
+			continue
+		}
+		pathItem, ok := paths[o.path].(map[string]any)
+		if !ok {
+			pathItem = make(map[string]any)
+			if o.rootPath {
+				pathItem["servers"] = []any{map[string]any{"url": "/"}}
+			}
+			paths[o.path] = pathItem
+		}
+		pathItem[strings.ToLower(o.method)] = o.document()
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "go-semantic-release plugin registry",
+			"description": "The API is served below /api/v3 with camelCase field names and below /api/v2 with the Go field names of the registry package. Each version serves its own OpenAPI document.",
+			"version":     s.config.Version,
+		},
+		"servers": []any{map[string]any{"url": fmt.Sprintf("/api/v%d", apiVersion)}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": openAPISchemas(apiVersion),
+			"securitySchemes": map[string]any{
+				"adminAccessToken": map[string]any{"type": "apiKey", "in": "header", "name": "Authorization"},
+			},
+		},
+	}
+}
+
+func (s *Server) getOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, s.openAPIDocument(getAPIVersion(r)))
+}
@@ -0,0 +1,410 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/internal/signing"
+	"github.com/go-semantic-release/plugin-registry/pkg/client"
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	documented := make(map[string]bool)
+	for _, o := range openAPIOperations {
+		documented[o.method+" "+o.path] = true
+	}
+	registered := make(map[string]bool)
+	err := chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route == "/" {
+			// the index route is not part of the API
+			return nil
+		}
+		route = strings.TrimPrefix(route, "/api/v3")
+		route = strings.TrimPrefix(route, "/api/v2")
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		registered[method+" "+route] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, registered, documented)
+}
+
+func collectRefs(v any, refs map[string]bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			if ref, ok := value.(string); ok && k == "$ref" {
+				refs[ref] = true
+				continue
+			}
+			collectRefs(value, refs)
+		}
+	case []any:
+		for _, value := range v {
+			collectRefs(value, refs)
+		}
+	}
+}
+
+func getOpenAPIDocument(t *testing.T, s *Server, apiVersion int) map[string]any {
+	rr := sendRequest(s, "GET", fmt.Sprintf("/api/v%d/openapi.json", apiVersion), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+	require.Equal(t, "3.1.0", doc["openapi"])
+	require.Equal(t, []any{map[string]any{"url": fmt.Sprintf("/api/v%d", apiVersion)}}, doc["servers"])
+
+	// all references point to the component schemas
+	refs := make(map[string]bool)
+	collectRefs(doc, refs)
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	for ref := range refs {
+		require.Contains(t, schemas, strings.TrimPrefix(ref, "#/components/schemas/"), ref)
+	}
+	return doc
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	doc := getOpenAPIDocument(t, s, 3)
+	paths := doc["paths"].(map[string]any)
+	batch := paths["/plugins/_batch"].(map[string]any)["post"].(map[string]any)
+	require.Equal(t, "batchGetPlugins", batch["operationId"])
+	require.Contains(t, batch["responses"], "202")
+	download := paths["/downloads/{os}/{arch}/semantic-release"].(map[string]any)
+	require.Equal(t, []any{map[string]any{"url": "/"}}, download["servers"])
+	update := paths["/plugins/{plugin}"].(map[string]any)["put"].(map[string]any)
+	require.Equal(t, []any{map[string]any{"adminAccessToken": []any{}}}, update["security"])
+	require.Contains(t, paths, "/schema.json")
+	plugin := doc["components"].(map[string]any)["schemas"].(map[string]any)["Plugin"].(map[string]any)
+	require.Contains(t, plugin["properties"], "fullName")
+}
+
+func TestOpenAPIDocumentV2(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+
+	doc := getOpenAPIDocument(t, s, 2)
+	paths := doc["paths"].(map[string]any)
+	require.Contains(t, paths, "/plugins/_batch")
+	// the JSON Schema is only served by the v3 API
+	require.NotContains(t, paths, "/schema.json")
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	plugin := schemas["Plugin"].(map[string]any)["properties"].(map[string]any)
+	require.Equal(t, map[string]any{"anyOf": []any{
+		map[string]any{"$ref": "#/components/schemas/PluginRelease"},
+		map[string]any{"type": "null"},
+	}}, plugin["LatestRelease"])
+	require.Equal(t, []any{"array", "null"}, plugin["Versions"].(map[string]any)["type"])
+
+	// the fields of the embedded batch request plugin are promoted, but optional
+	batchPlugin := schemas["BatchResponsePlugin"].(map[string]any)
+	require.Contains(t, batchPlugin["properties"], "FullName")
+	require.NotContains(t, batchPlugin["required"], "FullName")
+	require.Contains(t, batchPlugin["required"], "Version")
+}
+
+// validateSchema validates a decoded JSON value against the subset of JSON Schema that is used in the OpenAPI document.
+func validateSchema(schemas map[string]any, schema map[string]any, v any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateSchema(schemas, schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]any), v, path)
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if alternatives, ok := schema[keyword].([]any); ok {
+			for _, s := range alternatives {
+				if validateSchema(schemas, s.(map[string]any), v, path) == nil {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s: does not match any schema", path)
+		}
+	}
+	types, ok := schema["type"].([]string)
+	if !ok {
+		t, _ := schema["type"].(string)
+		types = []string{t}
+	}
+	if v == nil && contains(types, "null") {
+		return nil
+	}
+	switch types[0] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, v)
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing property %s", path, name)
+			}
+		}
+		for name, value := range obj {
+			propertySchema, ok := properties[name].(map[string]any)
+			if !ok {
+				propertySchema, ok = schema["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				if properties != nil {
+					return fmt.Errorf("%s: unknown property %s", path, name)
+				}
+				continue
+			}
+			if err := validateSchema(schemas, propertySchema, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, v)
+		}
+		for i, value := range values {
+			if err := validateSchema(schemas, schema["items"].(map[string]any), value, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, v)
+		}
+		if enum, ok := schema["enum"].([]string); ok && !contains(enum, str) {
+			return fmt.Errorf("%s: %s is not one of %v", path, str, enum)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, v)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func operationPathRegexp(path string) *regexp.Regexp {
+	return regexp.MustCompile("^" + openAPIPathParameterRegexp.ReplaceAllString(path, "[^/]+") + "$")
+}
+
+func findOperation(method, path string) *openAPIOperation {
+	for i := range openAPIOperations {
+		o := &openAPIOperations[i]
+		if o.method == method && operationPathRegexp(o.path).MatchString(path) {
+			return o
+		}
+	}
+	return nil
+}
+
+// checkOperation checks that the request of the client and the response of the server are described by the OpenAPI
+// document of the API version of the request.
+func checkOperation(s *Server, r *http.Request, requestBody []byte, rr *httptest.ResponseRecorder) error {
+	apiVersion := 2
+	path := strings.TrimPrefix(r.URL.Path, "/api/v2")
+	if p, ok := strings.CutPrefix(r.URL.Path, "/api/v3"); ok {
+		apiVersion, path = 3, p
+	}
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	o := findOperation(r.Method, path)
+	if o == nil {
+		return fmt.Errorf("%s %s is not documented", r.Method, path)
+	}
+	query := r.URL.Query()
+	for name := range query {
+		found := false
+		for _, p := range o.parameters {
+			found = found || p.name == name
+		}
+		if !found {
+			return fmt.Errorf("%s: query parameter %s is not documented", o.operationID, name)
+		}
+	}
+	for _, p := range o.parameters {
+		if p.required && !query.Has(p.name) {
+			return fmt.Errorf("%s: required query parameter %s is missing", o.operationID, p.name)
+		}
+	}
+	if o.v3Only && apiVersion != 3 {
+		return fmt.Errorf("%s is only served by the v3 API", o.operationID)
+	}
+	schemas := s.openAPIDocument(apiVersion)["components"].(map[string]any)["schemas"].(map[string]any)
+	if o.requestBody != nil {
+		var body any
+		if err := json.Unmarshal(requestBody, &body); err != nil {
+			return fmt.Errorf("%s: invalid request body: %w", o.operationID, err)
+		}
+		if err := validateSchema(schemas, o.requestBody, body, o.operationID+" request"); err != nil {
+			return err
+		}
+	}
+	if rr.Code == http.StatusNotModified && o.conditional {
+		return nil
+	}
+	responseSchema, ok := o.responses[rr.Code]
+	if !ok {
+		return fmt.Errorf("%s: status %d is not documented", o.operationID, rr.Code)
+	}
+	// redirects have no body
+	if rr.Code >= http.StatusMultipleChoices && rr.Code < http.StatusBadRequest {
+		return nil
+	}
+	if responseSchema == nil {
+		responseSchema = schemaRef("Error")
+	}
+	responseBody, err := decodeBody(rr.Header().Get("Content-Encoding"), rr.Body.Bytes())
+	if err != nil {
+		return err
+	}
+	var body any
+	if err := json.Unmarshal(responseBody, &body); err != nil {
+		return fmt.Errorf("%s: invalid response body: %w", o.operationID, err)
+	}
+	return validateSchema(schemas, responseSchema, body, o.operationID)
+}
+
+// newConformanceClient returns a client for the server that fails the test if a request or response is not described
+// by the OpenAPI document.
+func newConformanceClient(t *testing.T, s *Server) (*client.Client, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		r.Body = io.NopCloser(bytes.NewReader(requestBody))
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, r)
+		if err := checkOperation(s, r, requestBody, rr); err != nil {
+			t.Errorf("%s %s does not conform to the OpenAPI document: %v", r.Method, r.URL, err)
+		}
+		for k, v := range rr.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rr.Code)
+		_, _ = w.Write(rr.Body.Bytes())
+	}))
+	return client.New(ts.URL), ts.Close
+}
+
+func requireErrorResponse(t *testing.T, err error, statusCode int) {
+	var errResp *client.ErrorResponse
+	require.True(t, errors.As(err, &errResp), err)
+	require.Equal(t, statusCode, errResp.StatusCode)
+}
+
+func TestClientConformance(t *testing.T) {
+	s, _, closeFn := newTestServer(t)
+	defer closeFn()
+	s.signer, _ = signing.New(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	// the test does not use the emulator
+	s.batchJobs.store = newMemoryBatchJobStore()
+	c, closeClient := newConformanceClient(t, s)
+	defer closeClient()
+	ctx := context.Background()
+
+	plugins, err := c.GetPlugins(ctx)
+	require.NoError(t, err)
+	require.Len(t, plugins, len(config.Plugins))
+
+	keys, err := c.GetPublicKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*registry.PublicKey{s.signer.PublicKey()}, keys)
+
+	_, err = c.GetPlugin(ctx, "provider-unknown")
+	requireErrorResponse(t, err, http.StatusNotFound)
+
+	_, err = c.GetPluginReleaseNotesRange(ctx, "provider-git", "invalid", "")
+	requireErrorResponse(t, err, http.StatusBadRequest)
+
+	_, err = c.SendBatchRequest(ctx, &registry.BatchRequest{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchRequestPlugin{{FullName: "wrong"}},
+	})
+	requireErrorResponse(t, err, http.StatusBadRequest)
+
+	_, err = c.GetBatchJob(ctx, "unknown")
+	requireErrorResponse(t, err, http.StatusNotFound)
+
+	err = c.UpdatePlugin(ctx, "invalid-token", "provider-git")
+	requireErrorResponse(t, err, http.StatusUnauthorized)
+}
+
+func TestClientConformanceWithDatabase(t *testing.T) {
+	skipWithoutFirebaseEmulator(t)
+	killFirebaseEmulator, err := starsFirebaseEmulator()
+	require.NoError(t, err)
+	defer killFirebaseEmulator()
+	s, fsClient, closeFn := newTestServer(t)
+	defer closeFn()
+
+	dlServerCloseFn := bootstrapDatabase(t, fsClient)
+	defer dlServerCloseFn()
+
+	c, closeClient := newConformanceClient(t, s)
+	defer closeClient()
+	ctx := context.Background()
+
+	plugin, err := c.GetPlugin(ctx, "provider-git")
+	require.NoError(t, err)
+	require.Equal(t, "3.0.0", plugin.LatestRelease.Version)
+
+	summaries, err := c.SearchPlugins(ctx, &client.SearchOptions{Type: "provider", Query: "git"})
+	require.NoError(t, err)
+	require.NotEmpty(t, summaries)
+
+	release, err := c.GetPluginRelease(ctx, "provider-git", "1.1.0")
+	require.NoError(t, err)
+	require.Equal(t, "1.1.0", release.Version)
+
+	notes, err := c.GetPluginReleaseNotes(ctx, "provider-git", "1.1.0")
+	require.NoError(t, err)
+	require.Equal(t, "notes 1.1.0", notes.Notes)
+
+	notesRange, err := c.GetPluginReleaseNotesRange(ctx, "provider-git", "1.0.0", "1.2.0")
+	require.NoError(t, err)
+	require.Len(t, notesRange.Releases, 2)
+
+	batchResponse, err := c.SendBatchRequest(ctx, &registry.BatchRequest{
+		OS:      "darwin",
+		Arch:    "amd64",
+		Plugins: []*registry.BatchRequestPlugin{{FullName: "provider-git", VersionConstraint: "^1.0.0"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "1.2.0", batchResponse.Plugins[0].Version)
+}
@@ -9,7 +9,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-semantic-release/plugin-registry/internal/batch"
+	"github.com/go-semantic-release/plugin-registry/internal/blobcache"
 	"github.com/go-semantic-release/plugin-registry/internal/config"
+	"github.com/go-semantic-release/plugin-registry/internal/signing"
+	"github.com/go-semantic-release/plugin-registry/pkg/apiv3"
 	"github.com/google/go-github/v59/github"
 	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
@@ -25,14 +29,22 @@ type Server struct {
 	config   *config.ServerConfig
 	cache    *cache.Cache
 
-	ghSemaphore           *semaphore.Weighted
-	batchArchiveSemaphore *semaphore.Weighted
+	ghSemaphore   *semaphore.Weighted
+	batchArchives *batchArchivePool
+	blobCache     batch.BlobCache
+	batchJobs     *batchJobManager
+	signer        *signing.Signer
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
+// Close stops the background workers of the server.
+func (s *Server) Close() {
+	s.batchJobs.stop()
+}
+
 func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONError(w, r, http.StatusNotFound, fmt.Errorf("not found"))
 }
@@ -41,8 +53,8 @@ func (s *Server) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request)
 	s.writeJSONError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method now allowed"))
 }
 
-func (s *Server) indexHandler(w http.ResponseWriter, _ *http.Request) {
-	s.writeJSON(w, map[string]string{
+func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, map[string]string{
 		"service": "go-semantic-release plugin registry",
 		"stage":   s.config.Stage,
 		"version": s.config.Version,
@@ -53,46 +65,94 @@ func (s *Server) invalidateCacheHandler(w http.ResponseWriter, r *http.Request)
 	prefix := cacheKey(r.URL.Query().Get("prefix"))
 	deleted := s.invalidateByPrefix(prefix)
 	s.log.Warnf("invalidated cache for prefix %s (deleted=%d)", prefix, deleted)
-	s.writeJSON(w, map[string]any{
+	s.writeJSON(w, r, map[string]any{
 		"prefix":  prefix,
 		"deleted": deleted,
 	})
 }
 
+const (
+	// cacheControlPluginData is used for responses that change with every plugin update.
+	cacheControlPluginData = "public, max-age=300"
+	// cacheControlStaticData is used for responses that rarely change, e.g. published releases.
+	cacheControlStaticData = "public, max-age=3600"
+	cacheControlNoStore    = "no-store"
+)
+
 func (s *Server) apiV2Routes(r chi.Router) {
+	r.With(cacheControlMiddleware(cacheControlStaticData), s.conditionalMiddleware).Group(func(r chi.Router) {
+		r.Get("/openapi.json", s.getOpenAPIDocument)
+		r.Get("/keys", s.listPublicKeys)
+	})
+	r.With(cacheControlMiddleware(cacheControlPluginData), s.conditionalMiddleware, s.cacheMiddleware).Get("/platforms", s.getPlatformMatrix)
 	r.Route("/plugins", func(r chi.Router) {
-		r.With(s.cacheMiddleware).Group(func(r chi.Router) {
-			r.Get("/", s.listPlugins)
-			r.Get("/{plugin}", s.getPlugin)
-			r.Get("/{plugin}/versions", s.listPluginVersions)
-			r.Get("/{plugin}/versions/{version}", s.getPlugin)
+		r.With(s.conditionalMiddleware, s.cacheMiddleware).Group(func(r chi.Router) {
+			r.With(cacheControlMiddleware(cacheControlPluginData)).Group(func(r chi.Router) {
+				r.Get("/", s.listPlugins)
+				r.Get("/{plugin}", s.getPlugin)
+				r.Get("/{plugin}/versions", s.listPluginVersions)
+				r.Get("/{plugin}/platforms", s.listPluginPlatforms)
+				r.Get("/{plugin}/notes", s.listPluginReleaseNotes)
+			})
+			r.With(cacheControlMiddleware(cacheControlStaticData)).Group(func(r chi.Router) {
+				r.Get("/{plugin}/versions/{version}", s.getPlugin)
+				r.Get("/{plugin}/versions/{version}/notes", s.getPluginReleaseNotes)
+			})
 		})
-
-		r.Post("/_batch", s.batchGetPlugins)
-
-		// routes to update the plugin index
-		r.With(s.authMiddleware).Group(func(r chi.Router) {
-			r.Put("/", s.updateAllPlugins)
-			r.Put("/{plugin}", s.updatePlugin)
-			r.Put("/{plugin}/versions/{version}", s.updatePlugin)
-			r.Delete("/_cache", s.invalidateCacheHandler)
+		r.With(cacheControlMiddleware(cacheControlPluginData), s.conditionalMiddleware).Get("/{plugin}/resolve", s.resolvePluginHandler)
+
+		r.With(cacheControlMiddleware(cacheControlNoStore)).Group(func(r chi.Router) {
+			r.Post("/_batch", s.batchGetPlugins)
+			r.Get("/_batch/jobs/{id}", s.getBatchJob)
+
+			// routes to update the plugin index
+			r.With(s.authMiddleware).Group(func(r chi.Router) {
+				r.Put("/", s.updateAllPlugins)
+				r.Put("/{plugin}", s.updatePlugin)
+				r.Put("/{plugin}/versions/{version}", s.updatePlugin)
+				r.Delete("/_cache", s.invalidateCacheHandler)
+			})
 		})
 	})
 }
 
-func New(log *logrus.Logger, db *firestore.Client, ghClient *github.Client, storage *s3.Client, serverCfg *config.ServerConfig) *Server {
+// apiV3Routes serves the v2 routes with the camelCase v3 representation of the requests and responses
+// and the JSON Schema of the v3 types.
+func (s *Server) apiV3Routes(r chi.Router) {
+	r.Use(withAPIVersion(3))
+	r.With(cacheControlMiddleware(cacheControlStaticData), s.conditionalMiddleware).Get("/schema.json", s.getAPIV3Schema)
+	s.apiV2Routes(r)
+}
+
+func (s *Server) getAPIV3Schema(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, apiv3.Schema())
+}
+
+// New creates the registry server. If a signer is given, batch responses and archives are signed.
+func New(log *logrus.Logger, db *firestore.Client, ghClient *github.Client, storage *s3.Client, serverCfg *config.ServerConfig, signer *signing.Signer) *Server {
 	router := chi.NewRouter()
 	server := &Server{
-		router:                router,
-		log:                   log,
-		db:                    db,
-		ghClient:              ghClient,
-		storage:               storage,
-		config:                serverCfg,
-		cache:                 cache.New(15*time.Minute, 30*time.Minute),
-		ghSemaphore:           semaphore.NewWeighted(1),
-		batchArchiveSemaphore: semaphore.NewWeighted(1),
+		router:        router,
+		log:           log,
+		db:            db,
+		ghClient:      ghClient,
+		storage:       storage,
+		config:        serverCfg,
+		cache:         cache.New(15*time.Minute, 30*time.Minute),
+		ghSemaphore:   semaphore.NewWeighted(1),
+		batchArchives: newBatchArchivePool(serverCfg.BatchArchiveConcurrency),
+		signer:        signer,
+	}
+	if serverCfg.BlobCacheDir != "" {
+		blobCache, err := blobcache.New(serverCfg.BlobCacheDir, serverCfg.BlobCacheMaxSize)
+		if err != nil {
+			log.Errorf("could not create blob cache, continuing without it: %v", err)
+		} else {
+			log.Infof("using blob cache %s (size=%d)", serverCfg.BlobCacheDir, blobCache.Size())
+			server.blobCache = blobCache
+		}
 	}
+	server.batchJobs = newBatchJobManager(&firestoreBatchJobStore{db: db}, serverCfg.BatchJobWorkers, serverCfg.BatchJobQueueSize, server.runBatchJob)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Heartbeat("/ping"))
@@ -101,6 +161,7 @@ func New(log *logrus.Logger, db *firestore.Client, ghClient *github.Client, stor
 	// router.Use(middleware.Recoverer)
 
 	router.Use(middleware.Timeout(5 * time.Minute))
+	router.Use(server.compressMiddleware)
 
 	router.NotFound(server.notFoundHandler)
 	router.MethodNotAllowed(server.methodNotAllowedHandler)
@@ -108,6 +169,7 @@ func New(log *logrus.Logger, db *firestore.Client, ghClient *github.Client, stor
 	router.Get("/", server.indexHandler)
 
 	router.Route("/api/v2", server.apiV2Routes)
+	router.Route("/api/v3", server.apiV3Routes)
 
 	// downloads route
 	router.Get("/downloads/{os}/{arch}/semantic-release", server.downloadLatestSemRelBinary)
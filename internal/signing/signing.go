@@ -0,0 +1,53 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+// Signer creates detached ed25519 signatures for batch responses and archives.
+type Signer struct {
+	privateKey ed25519.PrivateKey
+	publicKey  *registry.PublicKey
+}
+
+// New creates a signer from a base64 encoded 32 byte ed25519 seed.
+func New(seed string) (*Signer, error) {
+	rawSeed, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key: %w", err)
+	}
+	if len(rawSeed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key size: %d (should be %d)", len(rawSeed), ed25519.SeedSize)
+	}
+	privateKey := ed25519.NewKeyFromSeed(rawSeed)
+	return &Signer{
+		privateKey: privateKey,
+		publicKey:  registry.NewPublicKey(privateKey.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+func (s *Signer) PublicKey() *registry.PublicKey {
+	return s.publicKey
+}
+
+func (s *Signer) Sign(payload []byte) *registry.Signature {
+	return &registry.Signature{
+		KeyID:     s.publicKey.KeyID,
+		Algorithm: registry.SignatureAlgorithmEd25519,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, payload)),
+	}
+}
+
+// SignBatchResponse signs the download hash and checksum of the batch response.
+func (s *Signer) SignBatchResponse(batchResponse *registry.BatchResponse) {
+	batchResponse.Signature = s.Sign(batchResponse.SigningPayload())
+}
+
+// SignArchive creates the detached signature of the archive with the given checksum.
+func (s *Signer) SignArchive(checksum string) *registry.Signature {
+	return s.Sign(registry.ArchiveSigningPayload(checksum))
+}
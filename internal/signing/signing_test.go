@@ -0,0 +1,61 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSigner(t *testing.T, seedByte byte) *Signer {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = seedByte
+	}
+	signer, err := New(base64.StdEncoding.EncodeToString(seed))
+	require.NoError(t, err)
+	return signer
+}
+
+func TestNewInvalidKey(t *testing.T) {
+	_, err := New("invalid")
+	require.Error(t, err)
+	_, err = New(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.ErrorContains(t, err, "invalid signing key size")
+}
+
+func TestSignBatchResponse(t *testing.T) {
+	signer := newTestSigner(t, 1)
+	batchResponse := registry.NewBatchResponse(&registry.BatchRequest{OS: "linux", Arch: "amd64"}, registry.BatchResponsePlugins{})
+	batchResponse.CalculateHash()
+	batchResponse.DownloadChecksum = "checksum"
+	signer.SignBatchResponse(batchResponse)
+
+	trustedKeys := []*registry.PublicKey{signer.PublicKey()}
+	require.NoError(t, batchResponse.VerifySignature(trustedKeys))
+
+	// untrusted key
+	require.ErrorIs(t, batchResponse.VerifySignature([]*registry.PublicKey{newTestSigner(t, 2).PublicKey()}), registry.ErrUntrustedKey)
+
+	// tampered checksum
+	batchResponse.DownloadChecksum = "other"
+	require.ErrorIs(t, batchResponse.VerifySignature(trustedKeys), registry.ErrInvalidSignature)
+
+	// missing signature
+	batchResponse.Signature = nil
+	require.ErrorIs(t, batchResponse.VerifySignature(trustedKeys), registry.ErrMissingSignature)
+}
+
+func TestSignArchive(t *testing.T) {
+	signer := newTestSigner(t, 1)
+	sig := signer.SignArchive("checksum")
+	trustedKeys := []*registry.PublicKey{signer.PublicKey()}
+	require.NoError(t, registry.VerifySignature(trustedKeys, registry.ArchiveSigningPayload("checksum"), sig))
+	require.ErrorIs(t, registry.VerifySignature(trustedKeys, registry.ArchiveSigningPayload("other"), sig), registry.ErrInvalidSignature)
+
+	parsedKey, err := registry.ParsePublicKey(signer.PublicKey().PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, signer.PublicKey(), parsedKey)
+}
@@ -0,0 +1,456 @@
+// Package apiv3 contains the types of the v3 API of the plugin registry. In contrast to the v2 API, which serializes
+// the types of the registry package with their Go field names, the v3 types have explicit camelCase JSON field
+// names that are stable and described by the JSON Schema returned by Schema.
+package apiv3
+
+import (
+	"time"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+)
+
+type Plugin struct {
+	FullName    string   `json:"fullName"`
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	License     string   `json:"license"`
+	Homepage    string   `json:"homepage"`
+	Maintainers []string `json:"maintainers"`
+	Keywords    []string `json:"keywords"`
+	// MinSemanticReleaseVersion is empty if the plugin does not require a minimum version of semantic-release.
+	MinSemanticReleaseVersion string         `json:"minSemanticReleaseVersion"`
+	LatestRelease             *PluginRelease `json:"latestRelease,omitempty"`
+	// Versions are omitted from plugin summaries.
+	Versions  []string  `json:"versions,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type PluginRelease struct {
+	Version                   string                  `json:"version"`
+	Prerelease                bool                    `json:"prerelease"`
+	CreatedAt                 time.Time               `json:"createdAt"`
+	Assets                    map[string]*PluginAsset `json:"assets"`
+	HTMLURL                   string                  `json:"htmlUrl"`
+	ReleaseNotes              string                  `json:"releaseNotes"`
+	SemanticReleaseConstraint string                  `json:"semanticReleaseConstraint"`
+	Requires                  []*PluginRelation       `json:"requires"`
+	Conflicts                 []*PluginRelation       `json:"conflicts"`
+	RegistryComputedChecksums bool                    `json:"registryComputedChecksums"`
+	Verification              *ReleaseVerification    `json:"verification,omitempty"`
+	UpdatedAt                 time.Time               `json:"updatedAt"`
+}
+
+type PluginAsset struct {
+	FileName          string `json:"fileName"`
+	URL               string `json:"url"`
+	OS                string `json:"os"`
+	Arch              string `json:"arch"`
+	Variant           string `json:"variant,omitempty"`
+	Checksum          string `json:"checksum"`
+	ChecksumAlgorithm string `json:"checksumAlgorithm" enum:"sha256,sha512"`
+	Size              int64  `json:"size"`
+	ContentType       string `json:"contentType"`
+	Digest            string `json:"digest"`
+	DownloadCount     int    `json:"downloadCount"`
+	// ArchiveType is omitted if the asset is a raw binary.
+	ArchiveType string `json:"archiveType,omitempty" enum:"tar.gz,zip"`
+}
+
+type PluginRelation struct {
+	FullName          string `json:"fullName"`
+	VersionConstraint string `json:"versionConstraint,omitempty"`
+}
+
+type ReleaseVerification struct {
+	SignatureVerified   bool      `json:"signatureVerified"`
+	SignatureType       string    `json:"signatureType,omitempty" enum:"minisign,cosign,gpg"`
+	ProvenanceVerified  bool      `json:"provenanceVerified"`
+	ProvenanceStatus    string    `json:"provenanceStatus,omitempty" enum:"verified,unverified"`
+	ProvenanceBuilderID string    `json:"provenanceBuilderId,omitempty"`
+	VerifiedAt          time.Time `json:"verifiedAt"`
+}
+
+type ReleaseNotes struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	HTMLURL   string    `json:"htmlUrl"`
+	Notes     string    `json:"notes"`
+}
+
+type ReleaseNotesRange struct {
+	FullName string          `json:"fullName"`
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Releases []*ReleaseNotes `json:"releases"`
+}
+
+type PlatformSupport struct {
+	Platform string   `json:"platform"`
+	OS       string   `json:"os"`
+	Arch     string   `json:"arch"`
+	Variant  string   `json:"variant,omitempty"`
+	Versions []string `json:"versions"`
+	Latest   bool     `json:"latest"`
+}
+
+type PluginPlatforms struct {
+	FullName      string             `json:"fullName"`
+	LatestVersion string             `json:"latestVersion"`
+	Platforms     []*PlatformSupport `json:"platforms"`
+}
+
+type PlatformMatrix struct {
+	Platforms []string            `json:"platforms"`
+	Plugins   map[string][]string `json:"plugins"`
+}
+
+type BatchRequestPlugin struct {
+	FullName          string `json:"fullName"`
+	VersionConstraint string `json:"versionConstraint,omitempty"`
+}
+
+type BatchRequest struct {
+	OS                     string                `json:"os"`
+	Arch                   string                `json:"arch"`
+	Variant                string                `json:"variant,omitempty" enum:"v5,v6,v7"`
+	Format                 string                `json:"format,omitempty" enum:"tar.gz,zip,tar.zst"`
+	SemanticReleaseVersion string                `json:"semanticReleaseVersion,omitempty"`
+	AutoComplete           bool                  `json:"autoComplete,omitempty"`
+	Plugins                []*BatchRequestPlugin `json:"plugins"`
+}
+
+type BatchResponsePlugin struct {
+	FullName          string `json:"fullName"`
+	VersionConstraint string `json:"versionConstraint"`
+	Version           string `json:"version"`
+	FileName          string `json:"fileName"`
+	URL               string `json:"url"`
+	Checksum          string `json:"checksum"`
+	ChecksumAlgorithm string `json:"checksumAlgorithm" enum:"sha256,sha512"`
+	Size              int64  `json:"size"`
+	ArchiveType       string `json:"archiveType,omitempty" enum:"tar.gz,zip"`
+	Platform          string `json:"platform"`
+	RequiredBy        string `json:"requiredBy,omitempty"`
+}
+
+type BatchResponse struct {
+	OS                     string                 `json:"os"`
+	Arch                   string                 `json:"arch"`
+	Variant                string                 `json:"variant,omitempty"`
+	Format                 string                 `json:"format" enum:"tar.gz,zip,tar.zst"`
+	SemanticReleaseVersion string                 `json:"semanticReleaseVersion,omitempty"`
+	AutoComplete           bool                   `json:"autoComplete,omitempty"`
+	Plugins                []*BatchResponsePlugin `json:"plugins"`
+	DownloadHash           string                 `json:"downloadHash"`
+	DownloadURL            string                 `json:"downloadUrl"`
+	DownloadChecksum       string                 `json:"downloadChecksum"`
+	Signature              *Signature             `json:"signature,omitempty"`
+	DownloadSignatureURL   string                 `json:"downloadSignatureUrl,omitempty"`
+}
+
+type BatchJob struct {
+	ID        string         `json:"id"`
+	Status    string         `json:"status" enum:"pending,running,succeeded,failed"`
+	Error     string         `json:"error,omitempty"`
+	Response  *BatchResponse `json:"response,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+type PublicKey struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+type Signature struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	Signature string `json:"signature"`
+}
+
+// Error is the body of all error responses.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func NewPlugin(p *registry.Plugin) *Plugin {
+	if p == nil {
+		return nil
+	}
+	return &Plugin{
+		FullName:                  p.FullName,
+		Type:                      p.Type,
+		Name:                      p.Name,
+		URL:                       p.URL,
+		Description:               p.Description,
+		License:                   p.License,
+		Homepage:                  p.Homepage,
+		Maintainers:               nonNilStrings(p.Maintainers),
+		Keywords:                  nonNilStrings(p.Keywords),
+		MinSemanticReleaseVersion: p.MinSemanticReleaseVersion,
+		LatestRelease:             NewPluginRelease(p.LatestRelease),
+		Versions:                  p.Versions,
+		UpdatedAt:                 p.UpdatedAt,
+	}
+}
+
+func newPluginRelations(relations []*registry.PluginRelation) []*PluginRelation {
+	ret := make([]*PluginRelation, len(relations))
+	for i, r := range relations {
+		ret[i] = &PluginRelation{FullName: r.FullName, VersionConstraint: r.VersionConstraint}
+	}
+	return ret
+}
+
+func NewPluginRelease(pr *registry.PluginRelease) *PluginRelease {
+	if pr == nil {
+		return nil
+	}
+	assets := make(map[string]*PluginAsset, len(pr.Assets))
+	for platform, asset := range pr.Assets {
+		assets[platform] = &PluginAsset{
+			FileName:          asset.FileName,
+			URL:               asset.URL,
+			OS:                asset.OS,
+			Arch:              asset.Arch,
+			Variant:           asset.Variant,
+			Checksum:          asset.Checksum,
+			ChecksumAlgorithm: string(asset.GetChecksumAlgorithm()),
+			Size:              asset.Size,
+			ContentType:       asset.ContentType,
+			Digest:            asset.Digest,
+			DownloadCount:     asset.DownloadCount,
+			ArchiveType:       string(asset.ArchiveType),
+		}
+	}
+	var verification *ReleaseVerification
+	if pr.Verification != nil {
+		verification = &ReleaseVerification{
+			SignatureVerified:   pr.Verification.SignatureVerified,
+			SignatureType:       string(pr.Verification.SignatureType),
+			ProvenanceVerified:  pr.Verification.ProvenanceVerified,
+			ProvenanceStatus:    string(pr.Verification.ProvenanceStatus),
+			ProvenanceBuilderID: pr.Verification.ProvenanceBuilderID,
+			VerifiedAt:          pr.Verification.VerifiedAt,
+		}
+	}
+	return &PluginRelease{
+		Version:                   pr.Version,
+		Prerelease:                pr.Prerelease,
+		CreatedAt:                 pr.CreatedAt,
+		Assets:                    assets,
+		HTMLURL:                   pr.HTMLURL,
+		ReleaseNotes:              pr.ReleaseNotes,
+		SemanticReleaseConstraint: pr.SemanticReleaseConstraint,
+		Requires:                  newPluginRelations(pr.Requires),
+		Conflicts:                 newPluginRelations(pr.Conflicts),
+		RegistryComputedChecksums: pr.RegistryComputedChecksums,
+		Verification:              verification,
+		UpdatedAt:                 pr.UpdatedAt,
+	}
+}
+
+func NewReleaseNotes(rn *registry.ReleaseNotes) *ReleaseNotes {
+	return &ReleaseNotes{
+		Version:   rn.Version,
+		CreatedAt: rn.CreatedAt,
+		HTMLURL:   rn.HTMLURL,
+		Notes:     rn.Notes,
+	}
+}
+
+func NewReleaseNotesRange(r *registry.ReleaseNotesRange) *ReleaseNotesRange {
+	releases := make([]*ReleaseNotes, len(r.Releases))
+	for i, rn := range r.Releases {
+		releases[i] = NewReleaseNotes(rn)
+	}
+	return &ReleaseNotesRange{FullName: r.FullName, From: r.From, To: r.To, Releases: releases}
+}
+
+func NewPluginPlatforms(pp *registry.PluginPlatforms) *PluginPlatforms {
+	platforms := make([]*PlatformSupport, len(pp.Platforms))
+	for i, ps := range pp.Platforms {
+		platforms[i] = &PlatformSupport{
+			Platform: ps.Platform,
+			OS:       ps.OS,
+			Arch:     ps.Arch,
+			Variant:  ps.Variant,
+			Versions: nonNilStrings(ps.Versions),
+			Latest:   ps.Latest,
+		}
+	}
+	return &PluginPlatforms{FullName: pp.FullName, LatestVersion: pp.LatestVersion, Platforms: platforms}
+}
+
+func NewPlatformMatrix(pm *registry.PlatformMatrix) *PlatformMatrix {
+	plugins := make(map[string][]string, len(pm.Plugins))
+	for fullName, platforms := range pm.Plugins {
+		plugins[fullName] = nonNilStrings(platforms)
+	}
+	return &PlatformMatrix{Platforms: nonNilStrings(pm.Platforms), Plugins: plugins}
+}
+
+func newSignature(s *registry.Signature) *Signature {
+	if s == nil {
+		return nil
+	}
+	return &Signature{KeyID: s.KeyID, Algorithm: s.Algorithm, Signature: s.Signature}
+}
+
+func NewBatchResponsePlugin(p *registry.BatchResponsePlugin) *BatchResponsePlugin {
+	return &BatchResponsePlugin{
+		FullName:          p.FullName,
+		VersionConstraint: p.VersionConstraint,
+		Version:           p.Version,
+		FileName:          p.FileName,
+		URL:               p.URL,
+		Checksum:          p.Checksum,
+		ChecksumAlgorithm: string(p.GetChecksumAlgorithm()),
+		Size:              p.Size,
+		ArchiveType:       string(p.ArchiveType),
+		Platform:          p.Platform,
+		RequiredBy:        p.RequiredBy,
+	}
+}
+
+func NewBatchResponse(b *registry.BatchResponse) *BatchResponse {
+	if b == nil {
+		return nil
+	}
+	plugins := make([]*BatchResponsePlugin, len(b.Plugins))
+	for i, p := range b.Plugins {
+		plugins[i] = NewBatchResponsePlugin(p)
+	}
+	return &BatchResponse{
+		OS:                     b.OS,
+		Arch:                   b.Arch,
+		Variant:                b.Variant,
+		Format:                 string(b.GetFormat()),
+		SemanticReleaseVersion: b.SemanticReleaseVersion,
+		AutoComplete:           b.AutoComplete,
+		Plugins:                plugins,
+		DownloadHash:           b.DownloadHash,
+		DownloadURL:            b.DownloadURL,
+		DownloadChecksum:       b.DownloadChecksum,
+		Signature:              newSignature(b.Signature),
+		DownloadSignatureURL:   b.DownloadSignatureURL,
+	}
+}
+
+// ToRegistry converts the batch response, e.g. to verify its hash and signature with the registry package.
+func (b *BatchResponse) ToRegistry() *registry.BatchResponse {
+	plugins := make(registry.BatchResponsePlugins, len(b.Plugins))
+	for i, p := range b.Plugins {
+		plugins[i] = &registry.BatchResponsePlugin{
+			BatchRequestPlugin: &registry.BatchRequestPlugin{FullName: p.FullName, VersionConstraint: p.VersionConstraint},
+			Version:            p.Version,
+			FileName:           p.FileName,
+			URL:                p.URL,
+			Checksum:           p.Checksum,
+			ChecksumAlgorithm:  registry.ChecksumAlgorithm(p.ChecksumAlgorithm),
+			Size:               p.Size,
+			ArchiveType:        registry.ArchiveType(p.ArchiveType),
+			Platform:           p.Platform,
+			RequiredBy:         p.RequiredBy,
+		}
+	}
+	var signature *registry.Signature
+	if b.Signature != nil {
+		signature = &registry.Signature{KeyID: b.Signature.KeyID, Algorithm: b.Signature.Algorithm, Signature: b.Signature.Signature}
+	}
+	return &registry.BatchResponse{
+		OS:                     b.OS,
+		Arch:                   b.Arch,
+		Variant:                b.Variant,
+		Format:                 registry.ArchiveFormat(b.Format),
+		SemanticReleaseVersion: b.SemanticReleaseVersion,
+		AutoComplete:           b.AutoComplete,
+		Plugins:                plugins,
+		DownloadHash:           b.DownloadHash,
+		DownloadURL:            b.DownloadURL,
+		DownloadChecksum:       b.DownloadChecksum,
+		Signature:              signature,
+		DownloadSignatureURL:   b.DownloadSignatureURL,
+	}
+}
+
+// ToRegistry converts the batch request to the request that is processed by the registry.
+func (b *BatchRequest) ToRegistry() *registry.BatchRequest {
+	plugins := make([]*registry.BatchRequestPlugin, len(b.Plugins))
+	for i, p := range b.Plugins {
+		plugins[i] = &registry.BatchRequestPlugin{FullName: p.FullName, VersionConstraint: p.VersionConstraint}
+	}
+	return &registry.BatchRequest{
+		OS:                     b.OS,
+		Arch:                   b.Arch,
+		Variant:                b.Variant,
+		Format:                 registry.ArchiveFormat(b.Format),
+		SemanticReleaseVersion: b.SemanticReleaseVersion,
+		AutoComplete:           b.AutoComplete,
+		Plugins:                plugins,
+	}
+}
+
+func NewBatchJob(j *registry.BatchJob) *BatchJob {
+	return &BatchJob{
+		ID:        j.ID,
+		Status:    string(j.Status),
+		Error:     j.Error,
+		Response:  NewBatchResponse(j.Response),
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+}
+
+func NewPublicKey(k *registry.PublicKey) *PublicKey {
+	return &PublicKey{KeyID: k.KeyID, Algorithm: k.Algorithm, PublicKey: k.PublicKey}
+}
+
+func convertSlice[T, V any](values []T, fn func(T) V) []V {
+	ret := make([]V, len(values))
+	for i, v := range values {
+		ret[i] = fn(v)
+	}
+	return ret
+}
+
+// Convert converts a value of the registry package to its v3 representation. Values of other types
+// (e.g. lists of plugin names or error messages) are returned unchanged.
+func Convert(v any) any {
+	switch value := v.(type) {
+	case *registry.Plugin:
+		return NewPlugin(value)
+	case []*registry.Plugin:
+		return convertSlice(value, NewPlugin)
+	case *registry.PluginRelease:
+		return NewPluginRelease(value)
+	case *registry.ReleaseNotes:
+		return NewReleaseNotes(value)
+	case *registry.ReleaseNotesRange:
+		return NewReleaseNotesRange(value)
+	case *registry.PluginPlatforms:
+		return NewPluginPlatforms(value)
+	case *registry.PlatformMatrix:
+		return NewPlatformMatrix(value)
+	case *registry.BatchResponsePlugin:
+		return NewBatchResponsePlugin(value)
+	case *registry.BatchResponse:
+		return NewBatchResponse(value)
+	case *registry.BatchJob:
+		return NewBatchJob(value)
+	case []*registry.PublicKey:
+		return convertSlice(value, NewPublicKey)
+	default:
+		return v
+	}
+}
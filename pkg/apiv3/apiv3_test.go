@@ -0,0 +1,94 @@
+package apiv3
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-semantic-release/plugin-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertPlugin(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := Convert(&registry.Plugin{
+		FullName: "provider-git",
+		Type:     "provider",
+		Name:     "git",
+		URL:      "https://github.com/go-semantic-release/provider-git",
+		LatestRelease: &registry.PluginRelease{
+			Version: "1.0.0",
+			HTMLURL: "https://github.com/go-semantic-release/provider-git/releases/tag/v1.0.0",
+			Assets: map[string]*registry.PluginAsset{
+				"linux/amd64": {FileName: "plugin_linux_amd64", OS: "linux", Arch: "amd64", Checksum: "abc"},
+			},
+			Requires: []*registry.PluginRelation{{FullName: "provider-github", VersionConstraint: "^1.0.0"}},
+		},
+		UpdatedAt: updatedAt,
+	})
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, "provider-git", decoded["fullName"])
+	require.Equal(t, "https://github.com/go-semantic-release/provider-git", decoded["url"])
+	require.Equal(t, []any{}, decoded["maintainers"])
+	require.Equal(t, "2024-01-02T03:04:05Z", decoded["updatedAt"])
+	require.NotContains(t, decoded, "versions")
+	latestRelease := decoded["latestRelease"].(map[string]any)
+	require.Equal(t, "https://github.com/go-semantic-release/provider-git/releases/tag/v1.0.0", latestRelease["htmlUrl"])
+	require.Equal(t, []any{map[string]any{"fullName": "provider-github", "versionConstraint": "^1.0.0"}}, latestRelease["requires"])
+	asset := latestRelease["assets"].(map[string]any)["linux/amd64"].(map[string]any)
+	require.Equal(t, "sha256", asset["checksumAlgorithm"])
+	require.NotContains(t, asset, "archiveType")
+}
+
+func TestConvertPassesThroughOtherValues(t *testing.T) {
+	names := []string{"provider-git"}
+	require.Equal(t, names, Convert(names))
+	errBody := map[string]string{"error": "not found"}
+	require.Equal(t, errBody, Convert(errBody))
+}
+
+func TestBatchResponseRoundTrip(t *testing.T) {
+	req := (&BatchRequest{
+		OS:      "linux",
+		Arch:    "amd64",
+		Plugins: []*BatchRequestPlugin{{FullName: "provider-git", VersionConstraint: "^1.0.0"}},
+	}).ToRegistry()
+	require.NoError(t, req.Validate())
+	pluginResponse := registry.NewBatchResponsePlugin(req.Plugins[0])
+	pluginResponse.Version = "1.2.0"
+	pluginResponse.Checksum = "abc"
+	br := registry.NewBatchResponse(req, registry.BatchResponsePlugins{pluginResponse})
+	br.CalculateHash()
+
+	converted := NewBatchResponse(br)
+	require.Equal(t, "tar.gz", converted.Format)
+	require.Equal(t, "sha256", converted.Plugins[0].ChecksumAlgorithm)
+	require.True(t, converted.ToRegistry().VerifyHash())
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	require.Equal(t, SchemaID, schema["$id"])
+	defs := schema["$defs"].(map[string]any)
+	require.Len(t, defs, len(SchemaTypes))
+
+	plugin := defs["Plugin"].(map[string]any)
+	properties := plugin["properties"].(map[string]any)
+	require.Equal(t, map[string]any{"$ref": "#/$defs/PluginRelease"}, properties["latestRelease"])
+	require.Equal(t, map[string]any{"type": "string", "format": "date-time"}, properties["updatedAt"])
+	require.Contains(t, plugin["required"], "fullName")
+	require.NotContains(t, plugin["required"], "versions")
+
+	release := defs["PluginRelease"].(map[string]any)["properties"].(map[string]any)
+	require.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"$ref": "#/$defs/PluginAsset"}}, release["assets"])
+
+	job := defs["BatchJob"].(map[string]any)["properties"].(map[string]any)
+	require.Equal(t, []string{"pending", "running", "succeeded", "failed"}, job["status"].(map[string]any)["enum"])
+
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+}
@@ -0,0 +1,29 @@
+package apiv3
+
+import "github.com/go-semantic-release/plugin-registry/internal/jsonschema"
+
+// SchemaID is the ID of the JSON Schema of the v3 API.
+const SchemaID = "https://registry.go-semantic-release.xyz/api/v3/schema.json"
+
+// SchemaTypes are the types that are described by the JSON Schema.
+var SchemaTypes = []any{
+	Plugin{}, PluginRelease{}, PluginAsset{}, PluginRelation{}, ReleaseVerification{}, ReleaseNotes{},
+	ReleaseNotesRange{}, PluginPlatforms{}, PlatformSupport{}, PlatformMatrix{}, BatchRequest{}, BatchRequestPlugin{},
+	BatchResponse{}, BatchResponsePlugin{}, BatchJob{}, PublicKey{}, Signature{}, Error{},
+}
+
+// Definitions returns the JSON Schema definitions of the SchemaTypes, which are derived from their json tags.
+// The definitions reference each other with the given prefix, e.g. #/components/schemas/ for OpenAPI documents.
+func Definitions(refPrefix string) map[string]any {
+	return jsonschema.Definitions(SchemaTypes, refPrefix, jsonschema.JSONTags)
+}
+
+// Schema returns the JSON Schema (draft 2020-12) of the v3 API types.
+func Schema() map[string]any {
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     SchemaID,
+		"title":   "go-semantic-release plugin registry API v3",
+		"$defs":   Definitions("#/$defs/"),
+	}
+}
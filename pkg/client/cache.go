@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedResponses limits the number of responses that are kept by the response cache.
+const maxCachedResponses = 256
+
+type cachedResponse struct {
+	header       http.Header
+	body         []byte
+	etag         string
+	lastModified string
+	// expiresAt is the time until the response can be used without revalidation.
+	expiresAt time.Time
+}
+
+func (cr *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.body)),
+		ContentLength: int64(len(cr.body)),
+		Request:       req,
+	}
+}
+
+// responseCache keeps the successful GET responses of the registry in memory. Cached responses are returned
+// without a request while they are fresh according to their Cache-Control max-age, afterward they are revalidated
+// with If-None-Match and If-Modified-Since.
+type responseCache struct {
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+	now       func() time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		responses: make(map[string]*cachedResponse),
+		now:       time.Now,
+	}
+}
+
+// parseCacheControl returns the max-age of the Cache-Control header and whether the response may be stored.
+func parseCacheControl(header string) (time.Duration, bool) {
+	maxAge := time.Duration(0)
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			maxAge = 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return maxAge, true
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses[key]
+}
+
+func (c *responseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = make(map[string]*cachedResponse)
+}
+
+func (c *responseCache) set(key string, cr *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.responses[key]; !ok && len(c.responses) >= maxCachedResponses {
+		// evict an arbitrary response to keep the cache bounded
+		for k := range c.responses {
+			delete(c.responses, k)
+			break
+		}
+	}
+	c.responses[key] = cr
+}
+
+// do sends the request, GET requests are answered from the cache or revalidated if possible.
+func (c *responseCache) do(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := httpClient.Do(req)
+		// successful unsafe requests may change the cached resources
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			c.clear()
+		}
+		return resp, err
+	}
+
+	key := req.URL.String()
+	cached := c.get(key)
+	if cached != nil {
+		if c.now().Before(cached.expiresAt) {
+			return cached.toResponse(req), nil
+		}
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, storable := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		if storable {
+			// cached responses are not modified, as they may be used concurrently
+			revalidated := *cached
+			revalidated.expiresAt = c.now().Add(maxAge)
+			c.set(key, &revalidated)
+		}
+		return cached.toResponse(req), nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || !storable || (etag == "" && lastModified == "" && maxAge == 0) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	cr := &cachedResponse{
+		header:       resp.Header.Clone(),
+		body:         body,
+		etag:         etag,
+		lastModified: lastModified,
+		expiresAt:    c.now().Add(maxAge),
+	}
+	c.set(key, cr)
+	return cr.toResponse(req), nil
+}
@@ -3,11 +3,15 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -29,8 +33,10 @@ func (e *ErrorResponse) Error() string {
 }
 
 type Client struct {
-	registryURL string
-	httpClient  *http.Client
+	registryURL   string
+	httpClient    *http.Client
+	trustedKeys   []*registry.PublicKey
+	responseCache *responseCache
 }
 
 func New(registryURL string) *Client {
@@ -46,12 +52,42 @@ func New(registryURL string) *Client {
 	}
 }
 
+// EnableResponseCache enables an in-memory cache of the registry responses. Responses are cached according to
+// their Cache-Control header and revalidated with their ETag and Last-Modified headers.
+func (c *Client) EnableResponseCache() {
+	c.responseCache = newResponseCache()
+}
+
+// SetTrustedKeys enables the signature verification of batch responses. Batch responses and archives
+// are only accepted if they are signed with one of the trusted keys.
+func (c *Client) SetTrustedKeys(keys ...*registry.PublicKey) {
+	c.trustedKeys = keys
+}
+
+func (c *Client) verifyBatchResponse(br *registry.BatchResponse) error {
+	if len(c.trustedKeys) == 0 {
+		return nil
+	}
+	if err := br.VerifySignature(c.trustedKeys); err != nil {
+		return fmt.Errorf("batch response verification failed: %w", err)
+	}
+	return nil
+}
+
 func setAuth(adminAccessToken string) func(r *http.Request) {
 	return func(r *http.Request) {
 		r.Header.Set("Authorization", adminAccessToken)
 	}
 }
 
+func setQueryParam(key, value string) func(r *http.Request) {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set(key, value)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
 func getPluginURL(pluginName string) string {
 	return fmt.Sprintf("plugins/%s", pluginName)
 }
@@ -73,12 +109,15 @@ func (c *Client) sendRequest(ctx context.Context, method, endpoint string, body
 	for _, f := range modifyRequestFns {
 		f(req)
 	}
-	return c.httpClient.Do(req)
+	if c.responseCache == nil {
+		return c.httpClient.Do(req)
+	}
+	return c.responseCache.do(c.httpClient, req)
 }
 
 func (c *Client) decodeResponse(resp *http.Response, v any) error {
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		var errResp ErrorResponse
 		err := json.NewDecoder(resp.Body).Decode(&errResp)
 		if err != nil {
@@ -107,6 +146,35 @@ func (c *Client) GetPlugins(ctx context.Context) ([]string, error) {
 	return plugins, nil
 }
 
+// SearchOptions filters the plugins returned by SearchPlugins.
+type SearchOptions struct {
+	// Type is the plugin type, e.g. provider.
+	Type string
+	// Query is searched for in the names, aliases and descriptions of the plugins.
+	Query string
+}
+
+// SearchPlugins returns the summaries (including the latest release) of all plugins that match the options.
+func (c *Client) SearchPlugins(ctx context.Context, opts *SearchOptions) ([]*registry.Plugin, error) {
+	modifyRequestFns := []func(r *http.Request){setQueryParam("expand", "true")}
+	if opts != nil && opts.Type != "" {
+		modifyRequestFns = append(modifyRequestFns, setQueryParam("type", opts.Type))
+	}
+	if opts != nil && opts.Query != "" {
+		modifyRequestFns = append(modifyRequestFns, setQueryParam("q", opts.Query))
+	}
+	resp, err := c.sendRequest(ctx, http.MethodGet, "plugins", nil, modifyRequestFns...)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*registry.Plugin
+	err = c.decodeResponse(resp, &plugins)
+	if err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
 func (c *Client) GetPlugin(ctx context.Context, pluginName string) (*registry.Plugin, error) {
 	resp, err := c.sendRequest(ctx, http.MethodGet, getPluginURL(pluginName), nil)
 	if err != nil {
@@ -133,6 +201,39 @@ func (c *Client) GetPluginRelease(ctx context.Context, pluginName, version strin
 	return &pr, nil
 }
 
+// GetPluginReleaseNotes returns the release notes of a plugin version.
+func (c *Client) GetPluginReleaseNotes(ctx context.Context, pluginName, version string) (*registry.ReleaseNotes, error) {
+	resp, err := c.sendRequest(ctx, http.MethodGet, fmt.Sprintf("plugins/%s/versions/%s/notes", pluginName, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	var notes registry.ReleaseNotes
+	err = c.decodeResponse(resp, &notes)
+	if err != nil {
+		return nil, err
+	}
+	return &notes, nil
+}
+
+// GetPluginReleaseNotesRange returns the release notes of all plugin versions after from up to and including to.
+// If to is empty, the release notes up to the latest version are returned.
+func (c *Client) GetPluginReleaseNotesRange(ctx context.Context, pluginName, from, to string) (*registry.ReleaseNotesRange, error) {
+	modifyRequestFns := []func(r *http.Request){setQueryParam("from", from)}
+	if to != "" {
+		modifyRequestFns = append(modifyRequestFns, setQueryParam("to", to))
+	}
+	resp, err := c.sendRequest(ctx, http.MethodGet, fmt.Sprintf("plugins/%s/notes", pluginName), nil, modifyRequestFns...)
+	if err != nil {
+		return nil, err
+	}
+	var notesRange registry.ReleaseNotesRange
+	err = c.decodeResponse(resp, &notesRange)
+	if err != nil {
+		return nil, err
+	}
+	return &notesRange, nil
+}
+
 func (c *Client) SendBatchRequest(ctx context.Context, batch *registry.BatchRequest) (*registry.BatchResponse, error) {
 	var bodyBuffer bytes.Buffer
 	err := json.NewEncoder(&bodyBuffer).Encode(batch)
@@ -148,9 +249,124 @@ func (c *Client) SendBatchRequest(ctx context.Context, batch *registry.BatchRequ
 	if err != nil {
 		return nil, err
 	}
+	if err := c.verifyBatchResponse(&br); err != nil {
+		return nil, err
+	}
 	return &br, nil
 }
 
+func (c *Client) SendAsyncBatchRequest(ctx context.Context, batch *registry.BatchRequest) (*registry.BatchJob, error) {
+	var bodyBuffer bytes.Buffer
+	err := json.NewEncoder(&bodyBuffer).Encode(batch)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.sendRequest(ctx, http.MethodPost, "plugins/_batch", &bodyBuffer, setQueryParam("async", "true"))
+	if err != nil {
+		return nil, err
+	}
+	var job registry.BatchJob
+	err = c.decodeResponse(resp, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *Client) GetBatchJob(ctx context.Context, jobID string) (*registry.BatchJob, error) {
+	resp, err := c.sendRequest(ctx, http.MethodGet, fmt.Sprintf("plugins/_batch/jobs/%s", jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var job registry.BatchJob
+	err = c.decodeResponse(resp, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitForBatchJob polls the batch job until it is done and returns the batch response once the archive is ready.
+func (c *Client) WaitForBatchJob(ctx context.Context, jobID string, pollInterval time.Duration) (*registry.BatchResponse, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetBatchJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		switch job.Status {
+		case registry.BatchJobStatusSucceeded:
+			if err := c.verifyBatchResponse(job.Response); err != nil {
+				return nil, err
+			}
+			return job.Response, nil
+		case registry.BatchJobStatusFailed:
+			return nil, fmt.Errorf("batch job %s failed: %s", jobID, job.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) GetPublicKeys(ctx context.Context) ([]*registry.PublicKey, error) {
+	resp, err := c.sendRequest(ctx, http.MethodGet, "keys", nil)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*registry.PublicKey
+	err = c.decodeResponse(resp, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DownloadBatchArchive downloads the archive of the batch response to dst. The archive is only written to dst
+// if its checksum matches the download checksum and, if trusted keys are set, the batch response is correctly signed.
+func (c *Client) DownloadBatchArchive(ctx context.Context, br *registry.BatchResponse, dst string) error {
+	if br.DownloadChecksum == "" {
+		return fmt.Errorf("batch response has no download checksum")
+	}
+	if err := c.verifyBatchResponse(br); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, br.DownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download archive: unexpected status code %d", resp.StatusCode)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), ".plugins-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmpFile, h), resp.Body)
+	closeErr := tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if checksum := hex.EncodeToString(h.Sum(nil)); checksum != br.DownloadChecksum {
+		return fmt.Errorf("archive checksum mismatch: %s (should be %s)", checksum, br.DownloadChecksum)
+	}
+	return os.Rename(tmpFile.Name(), dst)
+}
+
 func (c *Client) UpdatePlugins(ctx context.Context, adminAccessToken string) error {
 	return c.UpdatePluginRelease(ctx, adminAccessToken, "", "")
 }
@@ -2,11 +2,19 @@ package client
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-semantic-release/plugin-registry/internal/signing"
 	"github.com/go-semantic-release/plugin-registry/pkg/registry"
 
 	"github.com/stretchr/testify/assert"
@@ -27,6 +35,23 @@ func TestGetPlugins(t *testing.T) {
 	require.Equal(t, testData, plugins)
 }
 
+func TestSearchPlugins(t *testing.T) {
+	testData := []*registry.Plugin{{FullName: "provider-git", LatestRelease: &registry.PluginRelease{Version: "1.0.0"}}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/plugins", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("expand"))
+		assert.Equal(t, "provider", r.URL.Query().Get("type"))
+		assert.Equal(t, "git", r.URL.Query().Get("q"))
+		require.NoError(t, json.NewEncoder(w).Encode(testData))
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	plugins, err := c.SearchPlugins(context.Background(), &SearchOptions{Type: "provider", Query: "git"})
+	require.NoError(t, err)
+	require.Len(t, plugins, 1)
+	require.Equal(t, "1.0.0", plugins[0].LatestRelease.Version)
+}
+
 func TestGetPluginRelease(t *testing.T) {
 	testData := &registry.PluginRelease{
 		Version: "1.0.0",
@@ -49,6 +74,35 @@ func TestGetPluginRelease(t *testing.T) {
 	require.Equal(t, "plugin-darwin-amd64", pluginRelease.Assets["darwin/amd64"].FileName)
 }
 
+func TestGetPluginReleaseNotes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/plugins/plugin1/versions/1.0.0/notes":
+			require.NoError(t, json.NewEncoder(w).Encode(&registry.ReleaseNotes{Version: "1.0.0", Notes: "* fix"}))
+		case "/api/v2/plugins/plugin1/notes":
+			assert.Equal(t, "1.0.0", r.URL.Query().Get("from"))
+			assert.Equal(t, "1.2.0", r.URL.Query().Get("to"))
+			require.NoError(t, json.NewEncoder(w).Encode(&registry.ReleaseNotesRange{
+				From:     "1.0.0",
+				To:       "1.2.0",
+				Releases: []*registry.ReleaseNotes{{Version: "1.2.0"}, {Version: "1.1.0"}},
+			}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	notes, err := c.GetPluginReleaseNotes(context.Background(), "plugin1", "1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, "* fix", notes.Notes)
+
+	notesRange, err := c.GetPluginReleaseNotesRange(context.Background(), "plugin1", "1.0.0", "1.2.0")
+	require.NoError(t, err)
+	require.Len(t, notesRange.Releases, 2)
+	require.Equal(t, "1.2.0", notesRange.Releases[0].Version)
+}
+
 func TestSendBatchRequest(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, http.MethodPost, r.Method)
@@ -112,3 +166,192 @@ func TestUpdatePlugins(t *testing.T) {
 
 	require.Equal(t, 3, reqCount)
 }
+
+func TestSendAsyncBatchRequestAndWait(t *testing.T) {
+	reqCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() { reqCount++ }()
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "/api/v2/plugins/_batch", r.URL.Path)
+			assert.Equal(t, "true", r.URL.Query().Get("async"))
+			w.WriteHeader(http.StatusAccepted)
+			require.NoError(t, json.NewEncoder(w).Encode(&registry.BatchJob{ID: "job1", Status: registry.BatchJobStatusPending}))
+			return
+		}
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/v2/plugins/_batch/jobs/job1", r.URL.Path)
+		if reqCount < 3 {
+			require.NoError(t, json.NewEncoder(w).Encode(&registry.BatchJob{ID: "job1", Status: registry.BatchJobStatusRunning}))
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(&registry.BatchJob{
+			ID:       "job1",
+			Status:   registry.BatchJobStatusSucceeded,
+			Response: &registry.BatchResponse{OS: "darwin", Arch: "amd64", DownloadChecksum: "abc"},
+		}))
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	job, err := c.SendAsyncBatchRequest(context.Background(), &registry.BatchRequest{
+		OS:   "darwin",
+		Arch: "amd64",
+		Plugins: []*registry.BatchRequestPlugin{
+			{FullName: "plugin1", VersionConstraint: "^1.0.0"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "job1", job.ID)
+	require.Equal(t, registry.BatchJobStatusPending, job.Status)
+
+	batchResponse, err := c.WaitForBatchJob(context.Background(), job.ID, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "abc", batchResponse.DownloadChecksum)
+	require.Equal(t, 4, reqCount)
+}
+
+func TestWaitForFailedBatchJob(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(&registry.BatchJob{ID: "job1", Status: registry.BatchJobStatusFailed, Error: "could not create plugin archive"}))
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	_, err := c.WaitForBatchJob(context.Background(), "job1", time.Millisecond)
+	require.ErrorContains(t, err, "could not create plugin archive")
+}
+
+func newTestSigner(t *testing.T) *signing.Signer {
+	signer, err := signing.New(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	require.NoError(t, err)
+	return signer
+}
+
+func TestGetPublicKeys(t *testing.T) {
+	signer := newTestSigner(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/keys", r.URL.Path)
+		require.NoError(t, json.NewEncoder(w).Encode([]*registry.PublicKey{signer.PublicKey()}))
+	}))
+	defer ts.Close()
+	keys, err := New(ts.URL).GetPublicKeys(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*registry.PublicKey{signer.PublicKey()}, keys)
+}
+
+func TestSignedBatchRequestAndDownload(t *testing.T) {
+	signer := newTestSigner(t)
+	archive := []byte("archive")
+	archiveChecksum := sha256.Sum256(archive)
+	signResponse := true
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/archive.tar.gz" {
+			_, _ = w.Write(archive)
+			return
+		}
+		br := registry.NewBatchResponse(&registry.BatchRequest{OS: "linux", Arch: "amd64"}, registry.BatchResponsePlugins{})
+		br.CalculateHash()
+		br.DownloadURL = ts.URL + "/archive.tar.gz"
+		br.DownloadChecksum = hex.EncodeToString(archiveChecksum[:])
+		if signResponse {
+			signer.SignBatchResponse(br)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(br))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.SetTrustedKeys(signer.PublicKey())
+	batchRequest := &registry.BatchRequest{OS: "linux", Arch: "amd64"}
+	br, err := c.SendBatchRequest(context.Background(), batchRequest)
+	require.NoError(t, err)
+
+	dst := filepath.Join(t.TempDir(), "plugins.tar.gz")
+	require.NoError(t, c.DownloadBatchArchive(context.Background(), br, dst))
+	content, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, archive, content)
+
+	// tampered archive
+	archive = []byte("tampered")
+	tamperedDst := filepath.Join(t.TempDir(), "plugins.tar.gz")
+	require.ErrorContains(t, c.DownloadBatchArchive(context.Background(), br, tamperedDst), "archive checksum mismatch")
+	require.NoFileExists(t, tamperedDst)
+
+	// tampered response
+	br.DownloadChecksum = hex.EncodeToString(make([]byte, sha256.Size))
+	require.ErrorIs(t, c.DownloadBatchArchive(context.Background(), br, tamperedDst), registry.ErrInvalidSignature)
+
+	// unsigned response
+	signResponse = false
+	_, err = c.SendBatchRequest(context.Background(), batchRequest)
+	require.ErrorIs(t, err, registry.ErrMissingSignature)
+}
+
+func TestResponseCache(t *testing.T) {
+	requests := 0
+	cacheControl := "public, max-age=0"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", cacheControl)
+		if r.Method == http.MethodPut {
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]bool{"ok": true}))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		require.NoError(t, json.NewEncoder(w).Encode([]string{"plugin1"}))
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	ctx := context.Background()
+
+	// the cache is disabled by default
+	cacheControl = "public, max-age=60"
+	for i := 0; i < 2; i++ {
+		plugins, err := c.GetPlugins(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"plugin1"}, plugins)
+	}
+	require.Equal(t, 2, requests)
+
+	c.EnableResponseCache()
+	cacheControl = "public, max-age=0"
+	for i := 0; i < 2; i++ {
+		plugins, err := c.GetPlugins(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"plugin1"}, plugins)
+	}
+	// the second request has been revalidated
+	require.Equal(t, 4, requests)
+
+	// fresh responses are returned without a request
+	cacheControl = "public, max-age=60"
+	_, err := c.GetPlugins(ctx)
+	require.NoError(t, err)
+	_, err = c.GetPlugins(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 5, requests)
+
+	// updates invalidate the cache
+	require.NoError(t, c.UpdatePlugins(ctx, "token"))
+	cacheControl = "no-store"
+	_, err = c.GetPlugins(ctx)
+	require.NoError(t, err)
+	_, err = c.GetPlugins(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 8, requests)
+}
+
+func TestParseCacheControl(t *testing.T) {
+	maxAge, storable := parseCacheControl("public, max-age=300")
+	require.Equal(t, 5*time.Minute, maxAge)
+	require.True(t, storable)
+	maxAge, storable = parseCacheControl("max-age=300, no-cache")
+	require.Zero(t, maxAge)
+	require.True(t, storable)
+	_, storable = parseCacheControl("no-store")
+	require.False(t, storable)
+}
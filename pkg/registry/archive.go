@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveManifestFileName is the name of the manifest that is embedded in every batch archive.
+const ArchiveManifestFileName = "manifest.json"
+
+type ArchiveManifestFile struct {
+	Path     string
+	FullName string
+	Version  string
+	Size     int64
+	Checksum string
+}
+
+// ArchiveManifest describes the content of a batch archive. It contains the fields of the batch response that are
+// covered by the DownloadHash (the platform, the format and the resolved plugins) and the size and SHA-256 checksum
+// of every file.
+type ArchiveManifest struct {
+	*BatchResponse
+	Files []*ArchiveManifestFile
+}
+
+type archiveEntryFn func(name string, r io.Reader) error
+
+func walkTarArchive(r io.Reader, fn archiveEntryFn) error {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZipArchive(path string, fn archiveEntryFn) error {
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer zipReader.Close()
+	for _, zf := range zipReader.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", zf.Name, err)
+		}
+		err = fn(zf.Name, rc)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkArchive(path string, format ArchiveFormat, fn archiveEntryFn) error {
+	if format == ArchiveFormatZip {
+		return walkZipArchive(path, fn)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch format {
+	case ArchiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gzipReader.Close()
+		return walkTarArchive(gzipReader, fn)
+	case ArchiveFormatTarZst:
+		zstdReader, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		defer zstdReader.Close()
+		return walkTarArchive(zstdReader, fn)
+	default:
+		return fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+// ReadArchiveManifest reads the embedded manifest of a batch archive without verifying the archive.
+func ReadArchiveManifest(path string, format ArchiveFormat) (*ArchiveManifest, error) {
+	var manifest *ArchiveManifest
+	errFound := errors.New("manifest found")
+	err := walkArchive(path, format, func(name string, r io.Reader) error {
+		if name != ArchiveManifestFileName {
+			return nil
+		}
+		manifest = &ArchiveManifest{}
+		if err := json.NewDecoder(r).Decode(manifest); err != nil {
+			return fmt.Errorf("failed to decode manifest: %w", err)
+		}
+		return errFound
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("archive does not contain a manifest")
+	}
+	return manifest, nil
+}
+
+// VerifyArchive verifies that the batch archive contains exactly the files listed in its manifest
+// and that their sizes and checksums match. It returns the verified manifest.
+func VerifyArchive(path string, format ArchiveFormat) (*ArchiveManifest, error) {
+	var manifest *ArchiveManifest
+	found := make(map[string]*ArchiveManifestFile)
+	err := walkArchive(path, format, func(name string, r io.Reader) error {
+		if name == ArchiveManifestFileName {
+			manifest = &ArchiveManifest{}
+			if err := json.NewDecoder(r).Decode(manifest); err != nil {
+				return fmt.Errorf("failed to decode manifest: %w", err)
+			}
+			return nil
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		found[name] = &ArchiveManifestFile{Path: name, Size: n, Checksum: hex.EncodeToString(h.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil || manifest.BatchResponse == nil {
+		return nil, fmt.Errorf("archive does not contain a manifest")
+	}
+	if !manifest.VerifyHash() {
+		return nil, fmt.Errorf("manifest download hash mismatch")
+	}
+	if len(manifest.Files) != len(found) {
+		return nil, fmt.Errorf("archive contains %d files, manifest lists %d", len(found), len(manifest.Files))
+	}
+	for _, mf := range manifest.Files {
+		f := found[mf.Path]
+		if f == nil {
+			return nil, fmt.Errorf("file %s is missing", mf.Path)
+		}
+		if f.Size != mf.Size {
+			return nil, fmt.Errorf("file %s has an unexpected size: %d (should be %d)", mf.Path, f.Size, mf.Size)
+		}
+		if f.Checksum != mf.Checksum {
+			return nil, fmt.Errorf("checksum verification of %s failed", mf.Path)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testArchiveEntry struct {
+	name    string
+	content []byte
+}
+
+func writeTestArchive(t *testing.T, entries []testArchiveEntry) string {
+	path := filepath.Join(t.TempDir(), "plugins.tar.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gzipWriter := gzip.NewWriter(f)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, e := range entries {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: e.name, Mode: 0o755, Size: int64(len(e.content))}))
+		_, err := tarWriter.Write(e.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return path
+}
+
+func newTestManifest(t *testing.T, files map[string][]byte) []byte {
+	res := NewBatchResponse(&BatchRequest{OS: "linux", Arch: "amd64"}, BatchResponsePlugins{
+		newTestBatchResponsePlugin("provider-git", "latest", "1.0.0"),
+	})
+	res.CalculateHash()
+	manifest := &ArchiveManifest{BatchResponse: res}
+	for name, content := range files {
+		checksum := sha256.Sum256(content)
+		manifest.Files = append(manifest.Files, &ArchiveManifestFile{
+			Path:     name,
+			FullName: "provider-git",
+			Version:  "1.0.0",
+			Size:     int64(len(content)),
+			Checksum: hex.EncodeToString(checksum[:]),
+		})
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	return data
+}
+
+func TestVerifyArchive(t *testing.T) {
+	fileName := "linux_amd64/provider-git/1.0.0/provider-git"
+	content := []byte("test-file")
+	manifest := newTestManifest(t, map[string][]byte{fileName: content})
+
+	path := writeTestArchive(t, []testArchiveEntry{{ArchiveManifestFileName, manifest}, {fileName, content}})
+	verifiedManifest, err := VerifyArchive(path, ArchiveFormatTarGz)
+	require.NoError(t, err)
+	require.Len(t, verifiedManifest.Files, 1)
+	require.Equal(t, "provider-git", verifiedManifest.Plugins[0].FullName)
+
+	readManifest, err := ReadArchiveManifest(path, ArchiveFormatTarGz)
+	require.NoError(t, err)
+	require.Equal(t, verifiedManifest.DownloadHash, readManifest.DownloadHash)
+}
+
+func TestVerifyArchiveFailures(t *testing.T) {
+	fileName := "linux_amd64/provider-git/1.0.0/provider-git"
+	content := []byte("test-file")
+	manifest := newTestManifest(t, map[string][]byte{fileName: content})
+
+	testCases := []struct {
+		entries  []testArchiveEntry
+		expected string
+	}{
+		{
+			entries:  []testArchiveEntry{{fileName, content}},
+			expected: "archive does not contain a manifest",
+		},
+		{
+			entries:  []testArchiveEntry{{ArchiveManifestFileName, manifest}, {fileName, []byte("tampered")}},
+			expected: "unexpected size",
+		},
+		{
+			entries:  []testArchiveEntry{{ArchiveManifestFileName, manifest}, {fileName, []byte("test-fil3")}},
+			expected: "checksum verification",
+		},
+		{
+			entries:  []testArchiveEntry{{ArchiveManifestFileName, manifest}, {fileName, content}, {"other", content}},
+			expected: "archive contains 2 files, manifest lists 1",
+		},
+		{
+			entries:  []testArchiveEntry{{ArchiveManifestFileName, manifest}, {"other", content}},
+			expected: "is missing",
+		},
+	}
+	for _, testCase := range testCases {
+		_, err := VerifyArchive(writeTestArchive(t, testCase.entries), ArchiveFormatTarGz)
+		require.ErrorContains(t, err, testCase.expected)
+	}
+}
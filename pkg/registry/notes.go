@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ReleaseNotes are the release notes (markdown) of a plugin release.
+type ReleaseNotes struct {
+	Version   string
+	CreatedAt time.Time
+	HTMLURL   string
+	Notes     string
+}
+
+// GetReleaseNotes returns the release notes of the release.
+func (pr *PluginRelease) GetReleaseNotes() *ReleaseNotes {
+	return &ReleaseNotes{
+		Version:   pr.Version,
+		CreatedAt: pr.CreatedAt,
+		HTMLURL:   pr.HTMLURL,
+		Notes:     pr.ReleaseNotes,
+	}
+}
+
+// ReleaseNotesRange contains the release notes of all releases after From up to and including To.
+type ReleaseNotesRange struct {
+	FullName string
+	From     string
+	To       string
+	// Releases are sorted from the newest to the oldest version.
+	Releases []*ReleaseNotes
+}
+
+// NewReleaseNotesRange collects the release notes of the releases in the range (from, to].
+// Prereleases are skipped unless to is a prerelease.
+func NewReleaseNotesRange(fullName string, from, to *semver.Version, releases []*PluginRelease) *ReleaseNotesRange {
+	type versionedRelease struct {
+		version *semver.Version
+		release *PluginRelease
+	}
+	matching := make([]versionedRelease, 0)
+	for _, release := range releases {
+		v, err := semver.NewVersion(release.Version)
+		if err != nil || !v.GreaterThan(from) || v.GreaterThan(to) {
+			continue
+		}
+		if v.Prerelease() != "" && to.Prerelease() == "" {
+			continue
+		}
+		matching = append(matching, versionedRelease{version: v, release: release})
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].version.GreaterThan(matching[j].version)
+	})
+	ret := &ReleaseNotesRange{
+		FullName: fullName,
+		From:     from.String(),
+		To:       to.String(),
+		Releases: make([]*ReleaseNotes, len(matching)),
+	}
+	for i, m := range matching {
+		ret.Releases[i] = m.release.GetReleaseNotes()
+	}
+	return ret
+}
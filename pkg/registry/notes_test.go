@@ -0,0 +1,36 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReleaseNotesRange(t *testing.T) {
+	releases := make([]*PluginRelease, 0)
+	for _, v := range []string{"1.0.0", "1.10.0", "1.2.0", "1.9.0", "1.10.0-beta.1", "2.0.0"} {
+		releases = append(releases, &PluginRelease{Version: v, ReleaseNotes: "notes " + v, HTMLURL: "https://example.com/v" + v})
+	}
+	versions := func(r *ReleaseNotesRange) []string {
+		ret := make([]string, len(r.Releases))
+		for i, notes := range r.Releases {
+			ret[i] = notes.Version
+		}
+		return ret
+	}
+
+	notesRange := NewReleaseNotesRange("provider-git", semver.MustParse("1.0.0"), semver.MustParse("1.10.0"), releases)
+	require.Equal(t, "provider-git", notesRange.FullName)
+	require.Equal(t, "1.0.0", notesRange.From)
+	require.Equal(t, "1.10.0", notesRange.To)
+	require.Equal(t, []string{"1.10.0", "1.9.0", "1.2.0"}, versions(notesRange))
+	require.Equal(t, "notes 1.10.0", notesRange.Releases[0].Notes)
+	require.Equal(t, "https://example.com/v1.10.0", notesRange.Releases[0].HTMLURL)
+
+	notesRange = NewReleaseNotesRange("provider-git", semver.MustParse("1.2.0"), semver.MustParse("1.10.0-beta.1"), releases)
+	require.Equal(t, []string{"1.10.0-beta.1", "1.9.0"}, versions(notesRange))
+
+	notesRange = NewReleaseNotesRange("provider-git", semver.MustParse("2.0.0"), semver.MustParse("2.0.0"), releases)
+	require.Empty(t, notesRange.Releases)
+}
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PlatformSupport lists the releases of a plugin that provide an asset for a platform.
+type PlatformSupport struct {
+	Platform string
+	OS       string
+	Arch     string
+	Variant  string
+	// Versions are sorted from the newest to the oldest version.
+	Versions []string
+	// Latest reports whether the latest release of the plugin provides an asset for the platform.
+	Latest bool
+}
+
+// PluginPlatforms lists the platforms that are supported by the releases of a plugin.
+type PluginPlatforms struct {
+	FullName      string
+	LatestVersion string
+	Platforms     []*PlatformSupport
+}
+
+// PlatformMatrix lists the platforms that are supported by the latest releases of all plugins.
+type PlatformMatrix struct {
+	// Platforms are all platforms that are supported by at least one plugin.
+	Platforms []string
+	// Plugins maps the full name of every plugin to the platforms that are supported by its latest release.
+	Plugins map[string][]string
+}
+
+func compareVersions(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return va.Compare(vb)
+}
+
+// NewPluginPlatforms derives the supported platforms from the assets of the given releases.
+func NewPluginPlatforms(fullName, latestVersion string, releases []*PluginRelease) *PluginPlatforms {
+	platforms := make(map[string]*PlatformSupport)
+	for _, release := range releases {
+		for platform, asset := range release.Assets {
+			ps := platforms[platform]
+			if ps == nil {
+				ps = &PlatformSupport{Platform: platform, OS: asset.OS, Arch: asset.Arch, Variant: asset.Variant}
+				platforms[platform] = ps
+			}
+			ps.Versions = append(ps.Versions, release.Version)
+			if release.Version == latestVersion {
+				ps.Latest = true
+			}
+		}
+	}
+	ret := &PluginPlatforms{
+		FullName:      fullName,
+		LatestVersion: latestVersion,
+		Platforms:     make([]*PlatformSupport, 0, len(platforms)),
+	}
+	for _, ps := range platforms {
+		sort.Slice(ps.Versions, func(i, j int) bool {
+			return compareVersions(ps.Versions[i], ps.Versions[j]) > 0
+		})
+		ret.Platforms = append(ret.Platforms, ps)
+	}
+	sort.Slice(ret.Platforms, func(i, j int) bool {
+		return ret.Platforms[i].Platform < ret.Platforms[j].Platform
+	})
+	return ret
+}
+
+// NewPlatformMatrix derives the platform matrix from the latest releases of the plugins keyed by their full name.
+func NewPlatformMatrix(latestReleases map[string]*PluginRelease) *PlatformMatrix {
+	allPlatforms := make(map[string]struct{})
+	matrix := &PlatformMatrix{
+		Platforms: make([]string, 0),
+		Plugins:   make(map[string][]string, len(latestReleases)),
+	}
+	for fullName, release := range latestReleases {
+		platforms := make([]string, 0)
+		if release != nil {
+			for platform := range release.Assets {
+				platforms = append(platforms, platform)
+				allPlatforms[platform] = struct{}{}
+			}
+		}
+		sort.Strings(platforms)
+		matrix.Plugins[fullName] = platforms
+	}
+	for platform := range allPlatforms {
+		matrix.Platforms = append(matrix.Platforms, platform)
+	}
+	sort.Strings(matrix.Platforms)
+	return matrix
+}
@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPluginRelease(version string, platforms ...string) *PluginRelease {
+	assets := make(map[string]*PluginAsset)
+	for _, p := range platforms {
+		assets[p] = &PluginAsset{FileName: "plugin_" + p}
+	}
+	return &PluginRelease{Version: version, Assets: assets}
+}
+
+func TestNewPluginPlatforms(t *testing.T) {
+	platforms := NewPluginPlatforms("provider-git", "1.10.0", []*PluginRelease{
+		newTestPluginRelease("1.2.0", "linux/amd64", "darwin/amd64"),
+		newTestPluginRelease("1.10.0", "linux/amd64", "linux/arm/v7"),
+		newTestPluginRelease("1.9.0", "linux/amd64", "darwin/amd64"),
+	})
+	require.Equal(t, "provider-git", platforms.FullName)
+	require.Equal(t, "1.10.0", platforms.LatestVersion)
+	require.Len(t, platforms.Platforms, 3)
+	require.Equal(t, "darwin/amd64", platforms.Platforms[0].Platform)
+	require.Equal(t, []string{"1.9.0", "1.2.0"}, platforms.Platforms[0].Versions)
+	require.False(t, platforms.Platforms[0].Latest)
+	require.Equal(t, "linux/amd64", platforms.Platforms[1].Platform)
+	require.Equal(t, []string{"1.10.0", "1.9.0", "1.2.0"}, platforms.Platforms[1].Versions)
+	require.True(t, platforms.Platforms[1].Latest)
+	require.Equal(t, "linux/arm/v7", platforms.Platforms[2].Platform)
+	require.True(t, platforms.Platforms[2].Latest)
+}
+
+func TestNewPlatformMatrix(t *testing.T) {
+	matrix := NewPlatformMatrix(map[string]*PluginRelease{
+		"provider-git":     newTestPluginRelease("1.0.0", "linux/amd64", "darwin/arm64"),
+		"condition-github": newTestPluginRelease("1.0.0", "linux/amd64", "windows/amd64"),
+		"hooks-exec":       nil,
+	})
+	require.Equal(t, []string{"darwin/arm64", "linux/amd64", "windows/amd64"}, matrix.Platforms)
+	require.Equal(t, []string{"darwin/arm64", "linux/amd64"}, matrix.Plugins["provider-git"])
+	require.Equal(t, []string{"linux/amd64", "windows/amd64"}, matrix.Plugins["condition-github"])
+	require.Empty(t, matrix.Plugins["hooks-exec"])
+}
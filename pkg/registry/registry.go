@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
@@ -8,17 +9,26 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 type Plugin struct {
-	FullName      string
-	Type          string
-	Name          string
-	URL           string
-	Description   string
-	LatestRelease *PluginRelease
-	Versions      []string
-	UpdatedAt     time.Time
+	FullName    string
+	Type        string
+	Name        string
+	URL         string
+	Description string
+	// License is the SPDX identifier of the license of the plugin.
+	License     string
+	Homepage    string
+	Maintainers []string
+	Keywords    []string
+	// MinSemanticReleaseVersion is the minimum version of semantic-release the plugin is compatible with.
+	MinSemanticReleaseVersion string
+	LatestRelease             *PluginRelease
+	Versions                  []string
+	UpdatedAt                 time.Time
 }
 
 type PluginRelease struct {
@@ -26,7 +36,114 @@ type PluginRelease struct {
 	Prerelease bool
 	CreatedAt  time.Time
 	Assets     map[string]*PluginAsset
-	UpdatedAt  time.Time
+	// HTMLURL is the URL of the release page and ReleaseNotes is the body (markdown) of the release.
+	HTMLURL      string
+	ReleaseNotes string
+	// SemanticReleaseConstraint is the constraint of the compatible semantic-release versions, e.g. ">= 2.20.0".
+	// The release is compatible with all versions if it is empty.
+	SemanticReleaseConstraint string
+	// Requires lists the plugins that must be part of a batch together with the release and Conflicts
+	// lists the plugins that must not be part of it.
+	Requires  []*PluginRelation
+	Conflicts []*PluginRelation
+	// RegistryComputedChecksums reports whether the release does not provide checksums for all assets
+	// and the missing checksums have been computed by the registry.
+	RegistryComputedChecksums bool
+	Verification              *ReleaseVerification
+	UpdatedAt                 time.Time
+}
+
+// IsCompatible reports whether the release is compatible with the version of semantic-release.
+// Releases with an invalid constraint are not compatible with any version.
+func (pr *PluginRelease) IsCompatible(semRelVersion *semver.Version) bool {
+	if semRelVersion == nil || pr.SemanticReleaseConstraint == "" {
+		return true
+	}
+	constraint, err := semver.NewConstraint(pr.SemanticReleaseConstraint)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(semRelVersion)
+}
+
+type SignatureType string
+
+const (
+	SignatureTypeMinisign SignatureType = "minisign"
+	SignatureTypeCosign   SignatureType = "cosign"
+	SignatureTypeGPG      SignatureType = "gpg"
+)
+
+type ProvenanceStatus string
+
+const (
+	// ProvenanceStatusVerified is set if the attestation envelopes are signed by a configured key.
+	ProvenanceStatusVerified ProvenanceStatus = "verified"
+	// ProvenanceStatusUnverified is set if the attestations match the release, but their signatures could not be verified.
+	ProvenanceStatusUnverified ProvenanceStatus = "unverified"
+)
+
+// ReleaseVerification is the result of the verification of the upstream release. It is only set for plugins
+// that have a verification configured.
+type ReleaseVerification struct {
+	// SignatureVerified reports whether the signature of the checksum file has been verified.
+	SignatureVerified bool
+	SignatureType     SignatureType
+	// ProvenanceVerified reports whether the SLSA provenance attestation is signed by a configured key and its
+	// subjects match the asset checksums.
+	ProvenanceVerified bool
+	// ProvenanceStatus is empty if the release has no provenance attestation.
+	ProvenanceStatus    ProvenanceStatus
+	ProvenanceBuilderID string
+	VerifiedAt          time.Time
+}
+
+type ChecksumAlgorithm string
+
+const (
+	ChecksumAlgorithmSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumAlgorithmSHA512 ChecksumAlgorithm = "sha512"
+)
+
+// ChecksumAlgorithmFromLength returns the algorithm of a hex encoded checksum based on its length.
+// Checksums of other lengths (e.g. MD5 or SHA-1 checksums) are not supported.
+func ChecksumAlgorithmFromLength(checksum string) (ChecksumAlgorithm, error) {
+	switch len(checksum) {
+	case sha256.Size * 2:
+		return ChecksumAlgorithmSHA256, nil
+	case sha512.Size * 2:
+		return ChecksumAlgorithmSHA512, nil
+	}
+	return "", fmt.Errorf("unsupported checksum length %d", len(checksum))
+}
+
+// ArchiveType is the type of archive a plugin binary is published in.
+type ArchiveType string
+
+const (
+	ArchiveTypeTarGz ArchiveType = "tar.gz"
+	ArchiveTypeZip   ArchiveType = "zip"
+)
+
+var archiveTypeExtensions = []struct {
+	ext         string
+	archiveType ArchiveType
+}{
+	{".tar.gz", ArchiveTypeTarGz},
+	{".tgz", ArchiveTypeTarGz},
+	{".zip", ArchiveTypeZip},
+}
+
+// ArchiveTypeFromFileName returns the archive type of an asset based on its file extension
+// and the file name without the extension. The archive type is empty for raw binaries.
+func ArchiveTypeFromFileName(fileName string) (ArchiveType, string) {
+	lowerFileName := strings.ToLower(fileName)
+	for _, ate := range archiveTypeExtensions {
+		if strings.HasSuffix(lowerFileName, ate.ext) {
+			return ate.archiveType, fileName[:len(fileName)-len(ate.ext)]
+		}
+	}
+	return "", fileName
 }
 
 type PluginAsset struct {
@@ -34,7 +151,26 @@ type PluginAsset struct {
 	URL      string
 	OS       string
 	Arch     string
+	// Variant is the GOARM version (e.g. v7) of arm assets. It is empty if the asset does not target a specific version.
+	Variant  string
 	Checksum string
+	// ChecksumAlgorithm is empty for assets that have been stored before the algorithm was recorded, these are SHA-256 checksums.
+	ChecksumAlgorithm ChecksumAlgorithm
+	Size              int64
+	ContentType       string
+	// Digest is the digest of the asset reported by GitHub (e.g. sha256:<checksum>), it is empty for older releases.
+	Digest string
+	// DownloadCount is the number of downloads on GitHub at the time of the ingestion.
+	DownloadCount int
+	// ArchiveType is empty if the asset is a raw binary.
+	ArchiveType ArchiveType
+}
+
+func (p *PluginAsset) GetChecksumAlgorithm() ChecksumAlgorithm {
+	if p.ChecksumAlgorithm == "" {
+		return ChecksumAlgorithmSHA256
+	}
+	return p.ChecksumAlgorithm
 }
 
 type BatchRequestPlugin struct {
@@ -42,14 +178,73 @@ type BatchRequestPlugin struct {
 	VersionConstraint string
 }
 
+type ArchiveFormat string
+
+const (
+	ArchiveFormatTarGz  ArchiveFormat = "tar.gz"
+	ArchiveFormatZip    ArchiveFormat = "zip"
+	ArchiveFormatTarZst ArchiveFormat = "tar.zst"
+)
+
+var ArchiveFormats = []ArchiveFormat{ArchiveFormatTarGz, ArchiveFormatZip, ArchiveFormatTarZst}
+
+func (f ArchiveFormat) IsValid() bool {
+	for _, af := range ArchiveFormats {
+		if f == af {
+			return true
+		}
+	}
+	return false
+}
+
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case ArchiveFormatZip:
+		return "application/zip"
+	case ArchiveFormatTarZst:
+		return "application/zstd"
+	default:
+		return "application/gzip"
+	}
+}
+
+// MaxBatchPlugins is the maximum number of plugins of a batch, including the plugins that have been added to it
+// to complete the required plugins.
+const MaxBatchPlugins = 10
+
 type BatchRequest struct {
-	OS      string
-	Arch    string
-	Plugins []*BatchRequestPlugin
+	OS   string
+	Arch string
+	// Variant is the optional GOARM version (e.g. v7) for arm.
+	Variant string
+	Format  ArchiveFormat
+	// SemanticReleaseVersion is the optional version of the requesting semantic-release binary.
+	// If it is set, only plugin releases that are compatible with it are resolved.
+	SemanticReleaseVersion string
+	// AutoComplete adds the plugins that are required by the requested plugins, but are not part of the request.
+	AutoComplete bool
+	Plugins      []*BatchRequestPlugin
+}
+
+// GetPlatformKey returns the key of the assets of a platform in PluginRelease.Assets, e.g. linux/amd64 or linux/arm/v7.
+func GetPlatformKey(os, arch, variant string) string {
+	if variant != "" {
+		return fmt.Sprintf("%s/%s/%s", os, arch, variant)
+	}
+	return fmt.Sprintf("%s/%s", os, arch)
+}
+
+func isARMVariant(variant string) bool {
+	switch variant {
+	case "v5", "v6", "v7":
+		return true
+	default:
+		return false
+	}
 }
 
 func (b *BatchRequest) GetOSArch() string {
-	return fmt.Sprintf("%s/%s", b.OS, b.Arch)
+	return GetPlatformKey(b.OS, b.Arch, b.Variant)
 }
 
 func (b *BatchRequest) Validate() error {
@@ -61,18 +256,42 @@ func (b *BatchRequest) Validate() error {
 		return fmt.Errorf("at least one plugin is required")
 	}
 
-	if len(b.Plugins) > 10 {
-		return fmt.Errorf("maximum of 10 plugins allowed")
+	if len(b.Plugins) > MaxBatchPlugins {
+		return fmt.Errorf("maximum of %d plugins allowed", MaxBatchPlugins)
+	}
+
+	if b.Variant != "" && (strings.ToLower(b.Arch) != "arm" || !isARMVariant(strings.ToLower(b.Variant))) {
+		return fmt.Errorf("unsupported variant %s (only v5, v6 and v7 are supported for arm)", b.Variant)
+	}
+
+	if b.Format != "" && !ArchiveFormat(strings.ToLower(string(b.Format))).IsValid() {
+		return fmt.Errorf("unsupported archive format %s", b.Format)
+	}
+
+	if b.SemanticReleaseVersion != "" {
+		if _, err := semver.NewVersion(b.SemanticReleaseVersion); err != nil {
+			return fmt.Errorf("invalid semantic-release version %s", b.SemanticReleaseVersion)
+		}
 	}
 	return nil
 }
 
 type BatchResponsePlugin struct {
 	*BatchRequestPlugin
-	Version  string
-	FileName string
-	URL      string
-	Checksum string
+	Version           string
+	FileName          string
+	URL               string
+	Checksum          string
+	ChecksumAlgorithm ChecksumAlgorithm
+	// Size is zero if the size of the asset is unknown.
+	Size int64
+	// ArchiveType is empty if the asset is a raw binary, otherwise the binary is extracted into the batch archive.
+	ArchiveType ArchiveType
+	// Platform is the platform of the served asset (e.g. darwin/amd64). It differs from the requested platform
+	// if the plugin does not provide an asset for it and a fallback is served instead.
+	Platform string
+	// RequiredBy is the full name of the plugin that required the plugin, if it has been added by AutoComplete.
+	RequiredBy string
 }
 
 func NewBatchResponsePlugin(req *BatchRequestPlugin) *BatchResponsePlugin {
@@ -84,8 +303,20 @@ func NewBatchResponsePlugin(req *BatchRequestPlugin) *BatchResponsePlugin {
 	}
 }
 
+func (b *BatchResponsePlugin) GetChecksumAlgorithm() ChecksumAlgorithm {
+	if b.ChecksumAlgorithm == "" {
+		return ChecksumAlgorithmSHA256
+	}
+	return b.ChecksumAlgorithm
+}
+
 func (b *BatchResponsePlugin) String() string {
-	return fmt.Sprintf("%s@%s (version=%s) (checksum=%s)", b.FullName, b.VersionConstraint, b.Version, b.Checksum)
+	s := fmt.Sprintf("%s@%s (version=%s) (checksum=%s)", b.FullName, b.VersionConstraint, b.Version, b.Checksum)
+	// SHA-256 is not part of the string to keep the hashes of existing archives stable
+	if algorithm := b.GetChecksumAlgorithm(); algorithm != ChecksumAlgorithmSHA256 {
+		s += fmt.Sprintf(" (algorithm=%s)", algorithm)
+	}
+	return s
 }
 
 func (b *BatchResponsePlugin) Hash() []byte {
@@ -126,34 +357,95 @@ func (b BatchResponsePlugins) Has(fullName string) bool {
 }
 
 type BatchResponse struct {
-	OS               string
-	Arch             string
-	Plugins          BatchResponsePlugins
-	DownloadHash     string
-	DownloadURL      string
-	DownloadChecksum string
+	OS      string
+	Arch    string
+	Variant string
+	Format  ArchiveFormat
+	// SemanticReleaseVersion is the normalized semantic-release version of the request.
+	SemanticReleaseVersion string
+	AutoComplete           bool
+	Plugins                BatchResponsePlugins
+	DownloadHash           string
+	DownloadURL            string
+	DownloadChecksum       string
+	// Signature is the registry's signature of the SigningPayload, it is only set if signing is enabled.
+	Signature *Signature
+	// DownloadSignatureURL points to the detached signature of the archive.
+	DownloadSignatureURL string
 }
 
 func NewBatchResponse(req *BatchRequest, plugins BatchResponsePlugins) *BatchResponse {
 	sort.Sort(plugins)
+	format := ArchiveFormat(strings.ToLower(string(req.Format)))
+	if format == "" {
+		format = ArchiveFormatTarGz
+	}
+	semRelVersion := ""
+	if v, err := semver.NewVersion(req.SemanticReleaseVersion); err == nil {
+		semRelVersion = v.String()
+	}
 	return &BatchResponse{
-		OS:      strings.ToLower(req.OS),
-		Arch:    strings.ToLower(req.Arch),
-		Plugins: plugins,
+		OS:                     strings.ToLower(req.OS),
+		Arch:                   strings.ToLower(req.Arch),
+		Variant:                strings.ToLower(req.Variant),
+		Format:                 format,
+		SemanticReleaseVersion: semRelVersion,
+		AutoComplete:           req.AutoComplete,
+		Plugins:                plugins,
+	}
+}
+
+func (b *BatchResponse) GetFormat() ArchiveFormat {
+	if b.Format == "" {
+		return ArchiveFormatTarGz
 	}
+	return b.Format
 }
 
 func (b *BatchResponse) GetOSArch() string {
-	return fmt.Sprintf("%s/%s", b.OS, b.Arch)
+	return GetPlatformKey(b.OS, b.Arch, b.Variant)
+}
+
+// GetSemanticReleaseVersion returns the semantic-release version of the request or nil if it is not set.
+func (b *BatchResponse) GetSemanticReleaseVersion() *semver.Version {
+	if b.SemanticReleaseVersion == "" {
+		return nil
+	}
+	v, err := semver.NewVersion(b.SemanticReleaseVersion)
+	if err != nil {
+		return nil
+	}
+	return v
 }
 
 func (b *BatchResponse) Hash() []byte {
 	h := sha512.New512_256()
 	_, _ = io.WriteString(h, b.GetOSArch())
+	// the default format is not part of the hash to keep the hashes of existing archives stable
+	if format := b.GetFormat(); format != ArchiveFormatTarGz {
+		_, _ = io.WriteString(h, string(format))
+	}
 	_, _ = h.Write(b.Plugins.Hash())
 	return h.Sum(nil)
 }
 
+// RequestHash identifies the batch request before its plugins are resolved. The semantic-release version and
+// AutoComplete change the resolved plugins, but not the archive of the resolved plugins, so they are only part of this hash.
+func (b *BatchResponse) RequestHash() []byte {
+	if b.SemanticReleaseVersion == "" && !b.AutoComplete {
+		return b.Hash()
+	}
+	h := sha512.New512_256()
+	_, _ = h.Write(b.Hash())
+	if b.SemanticReleaseVersion != "" {
+		_, _ = io.WriteString(h, "semantic-release@"+b.SemanticReleaseVersion)
+	}
+	if b.AutoComplete {
+		_, _ = io.WriteString(h, "auto-complete")
+	}
+	return h.Sum(nil)
+}
+
 func (b *BatchResponse) CalculateHash() {
 	b.DownloadHash = hex.EncodeToString(b.Hash())
 }
@@ -162,3 +454,25 @@ func (b *BatchResponse) VerifyHash() bool {
 	calculatedHash := hex.EncodeToString(b.Hash())
 	return calculatedHash == b.DownloadHash
 }
+
+type BatchJobStatus string
+
+const (
+	BatchJobStatusPending   BatchJobStatus = "pending"
+	BatchJobStatusRunning   BatchJobStatus = "running"
+	BatchJobStatusSucceeded BatchJobStatus = "succeeded"
+	BatchJobStatusFailed    BatchJobStatus = "failed"
+)
+
+type BatchJob struct {
+	ID        string
+	Status    BatchJobStatus
+	Error     string
+	Response  *BatchResponse
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (j *BatchJob) IsDone() bool {
+	return j.Status == BatchJobStatusSucceeded || j.Status == BatchJobStatusFailed
+}
@@ -2,8 +2,10 @@ package registry
 
 import (
 	"encoding/hex"
+	"strings"
 	"testing"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,3 +57,122 @@ func TestBatchRequestHash(t *testing.T) {
 		require.Equal(t, testCase.expected, hex.EncodeToString(actual))
 	}
 }
+
+func TestBatchRequestHashWithFormat(t *testing.T) {
+	plugins := BatchResponsePlugins{newTestBatchResponsePlugin("foo", "^1.0.0", "1.2.3")}
+	tarGzHash := NewBatchResponse(&BatchRequest{OS: "darwin", Arch: "amd64"}, plugins).Hash()
+	explicitTarGzHash := NewBatchResponse(&BatchRequest{OS: "darwin", Arch: "amd64", Format: ArchiveFormatTarGz}, plugins).Hash()
+	zipRes := NewBatchResponse(&BatchRequest{OS: "darwin", Arch: "amd64", Format: "ZIP"}, plugins)
+	require.Equal(t, ArchiveFormatZip, zipRes.Format)
+	require.Equal(t, tarGzHash, explicitTarGzHash)
+	require.NotEqual(t, tarGzHash, zipRes.Hash())
+}
+
+func TestBatchPluginHashWithChecksumAlgorithm(t *testing.T) {
+	plugin := newTestBatchResponsePlugin("foo", "^1.0.0", "1.2.3")
+	hash := hex.EncodeToString(plugin.Hash())
+	plugin.ChecksumAlgorithm = ChecksumAlgorithmSHA256
+	require.Equal(t, hash, hex.EncodeToString(plugin.Hash()))
+	plugin.ChecksumAlgorithm = ChecksumAlgorithmSHA512
+	require.NotEqual(t, hash, hex.EncodeToString(plugin.Hash()))
+}
+
+func TestChecksumAlgorithmFromLength(t *testing.T) {
+	algorithm, err := ChecksumAlgorithmFromLength(strings.Repeat("a", 64))
+	require.NoError(t, err)
+	require.Equal(t, ChecksumAlgorithmSHA256, algorithm)
+	algorithm, err = ChecksumAlgorithmFromLength(strings.Repeat("a", 128))
+	require.NoError(t, err)
+	require.Equal(t, ChecksumAlgorithmSHA512, algorithm)
+	// MD5 and SHA-1
+	for _, length := range []int{32, 40} {
+		_, err = ChecksumAlgorithmFromLength(strings.Repeat("a", length))
+		require.Error(t, err)
+	}
+}
+
+func TestBatchRequestValidateFormat(t *testing.T) {
+	req := &BatchRequest{OS: "linux", Arch: "amd64", Plugins: []*BatchRequestPlugin{{FullName: "provider-git"}}}
+	for _, format := range []ArchiveFormat{"", ArchiveFormatTarGz, ArchiveFormatZip, ArchiveFormatTarZst} {
+		req.Format = format
+		require.NoError(t, req.Validate())
+	}
+	req.Format = "rar"
+	require.ErrorContains(t, req.Validate(), "unsupported archive format")
+}
+
+func TestArchiveTypeFromFileName(t *testing.T) {
+	testCases := []struct {
+		fileName    string
+		archiveType ArchiveType
+		trimmed     string
+	}{
+		{"plugin_linux_amd64", "", "plugin_linux_amd64"},
+		{"plugin_windows_amd64.exe", "", "plugin_windows_amd64.exe"},
+		{"plugin_linux_amd64.tar.gz", ArchiveTypeTarGz, "plugin_linux_amd64"},
+		{"plugin_linux_amd64.TGZ", ArchiveTypeTarGz, "plugin_linux_amd64"},
+		{"plugin_windows_amd64.zip", ArchiveTypeZip, "plugin_windows_amd64"},
+	}
+	for _, tc := range testCases {
+		archiveType, trimmed := ArchiveTypeFromFileName(tc.fileName)
+		require.Equal(t, tc.archiveType, archiveType, tc.fileName)
+		require.Equal(t, tc.trimmed, trimmed, tc.fileName)
+	}
+}
+
+func TestBatchRequestVariant(t *testing.T) {
+	req := &BatchRequest{OS: "linux", Arch: "arm", Variant: "v7", Plugins: []*BatchRequestPlugin{{FullName: "provider-git"}}}
+	require.NoError(t, req.Validate())
+	require.Equal(t, "linux/arm/v7", req.GetOSArch())
+	res := NewBatchResponse(req, BatchResponsePlugins{newTestBatchResponsePlugin("provider-git", "", "1.0.0")})
+	require.Equal(t, "linux/arm/v7", res.GetOSArch())
+	hash := hex.EncodeToString(res.Hash())
+	res.Variant = ""
+	require.NotEqual(t, hash, hex.EncodeToString(res.Hash()))
+
+	req.Variant = "v8"
+	require.ErrorContains(t, req.Validate(), "unsupported variant")
+	req.Arch, req.Variant = "arm64", "v7"
+	require.ErrorContains(t, req.Validate(), "unsupported variant")
+}
+
+func TestBatchRequestSemanticReleaseVersion(t *testing.T) {
+	req := &BatchRequest{OS: "linux", Arch: "amd64", SemanticReleaseVersion: "v2.20.0", Plugins: []*BatchRequestPlugin{{FullName: "provider-git"}}}
+	require.NoError(t, req.Validate())
+	res := NewBatchResponse(req, BatchResponsePlugins{newTestBatchResponsePlugin("provider-git", "", "1.0.0")})
+	require.Equal(t, "2.20.0", res.SemanticReleaseVersion)
+	require.Equal(t, "2.20.0", res.GetSemanticReleaseVersion().String())
+	hash, requestHash := hex.EncodeToString(res.Hash()), hex.EncodeToString(res.RequestHash())
+	require.NotEqual(t, hash, requestHash)
+
+	// the archive hash does not depend on the semantic-release version
+	res.SemanticReleaseVersion = ""
+	require.Nil(t, res.GetSemanticReleaseVersion())
+	require.Equal(t, hash, hex.EncodeToString(res.Hash()))
+	require.Equal(t, hash, hex.EncodeToString(res.RequestHash()))
+
+	req.SemanticReleaseVersion = "latest"
+	require.ErrorContains(t, req.Validate(), "invalid semantic-release version")
+}
+
+func TestPluginReleaseIsCompatible(t *testing.T) {
+	pr := &PluginRelease{Version: "2.0.0"}
+	require.True(t, pr.IsCompatible(nil))
+	require.True(t, pr.IsCompatible(semver.MustParse("1.0.0")))
+	pr.SemanticReleaseConstraint = ">= 2.20.0"
+	require.True(t, pr.IsCompatible(nil))
+	require.False(t, pr.IsCompatible(semver.MustParse("2.19.1")))
+	require.True(t, pr.IsCompatible(semver.MustParse("2.20.0")))
+	pr.SemanticReleaseConstraint = "invalid"
+	require.False(t, pr.IsCompatible(semver.MustParse("2.20.0")))
+}
+
+func TestBatchRequestAutoComplete(t *testing.T) {
+	req := &BatchRequest{OS: "linux", Arch: "amd64", AutoComplete: true, Plugins: []*BatchRequestPlugin{{FullName: "hooks-goreleaser"}}}
+	res := NewBatchResponse(req, BatchResponsePlugins{newTestBatchResponsePlugin("hooks-goreleaser", "", "1.0.0")})
+	require.True(t, res.AutoComplete)
+	requestHash := hex.EncodeToString(res.RequestHash())
+	require.NotEqual(t, hex.EncodeToString(res.Hash()), requestHash)
+	res.SemanticReleaseVersion = "2.20.0"
+	require.NotEqual(t, requestHash, hex.EncodeToString(res.RequestHash()))
+}
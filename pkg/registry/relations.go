@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PluginRelation refers to the releases of another plugin that a release requires or conflicts with.
+type PluginRelation struct {
+	FullName string
+	// VersionConstraint is empty if the relation applies to all versions of the plugin.
+	VersionConstraint string
+}
+
+// ParsePluginRelation parses a relation in the format <full name>[@<version constraint>], e.g. provider-github@^1.0.0.
+func ParsePluginRelation(s string) (*PluginRelation, error) {
+	fullName, versionConstraint, _ := strings.Cut(strings.TrimSpace(s), "@")
+	fullName = strings.ToLower(strings.TrimSpace(fullName))
+	if !strings.Contains(fullName, "-") {
+		return nil, fmt.Errorf("plugin relation %s has an invalid plugin name", s)
+	}
+	relation := &PluginRelation{FullName: fullName}
+	if versionConstraint = strings.TrimSpace(versionConstraint); versionConstraint != "" {
+		constraint, err := semver.NewConstraint(versionConstraint)
+		if err != nil {
+			return nil, fmt.Errorf("plugin relation %s has an invalid version constraint: %w", s, err)
+		}
+		relation.VersionConstraint = constraint.String()
+	}
+	return relation, nil
+}
+
+func (r *PluginRelation) String() string {
+	if r.VersionConstraint == "" {
+		return r.FullName
+	}
+	return fmt.Sprintf("%s@%s", r.FullName, r.VersionConstraint)
+}
+
+// Matches reports whether the version of the related plugin satisfies the version constraint of the relation.
+func (r *PluginRelation) Matches(version string) bool {
+	if r.VersionConstraint == "" {
+		return true
+	}
+	constraint, err := semver.NewConstraint(r.VersionConstraint)
+	if err != nil {
+		return false
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(v)
+}
@@ -0,0 +1,28 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePluginRelation(t *testing.T) {
+	relation, err := ParsePluginRelation("Provider-GitHub")
+	require.NoError(t, err)
+	require.Equal(t, &PluginRelation{FullName: "provider-github"}, relation)
+	require.Equal(t, "provider-github", relation.String())
+	require.True(t, relation.Matches("0.1.0"))
+
+	relation, err = ParsePluginRelation("provider-github@^1.2.0")
+	require.NoError(t, err)
+	require.Equal(t, "provider-github", relation.FullName)
+	require.Equal(t, "provider-github@^1.2.0", relation.String())
+	require.True(t, relation.Matches("1.5.0"))
+	require.False(t, relation.Matches("2.0.0"))
+	require.False(t, relation.Matches("invalid"))
+
+	_, err = ParsePluginRelation("github")
+	require.ErrorContains(t, err, "invalid plugin name")
+	_, err = ParsePluginRelation("provider-github@invalid")
+	require.ErrorContains(t, err, "invalid version constraint")
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const SignatureAlgorithmEd25519 = "ed25519"
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrUntrustedKey     = errors.New("signature was created with an untrusted key")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// PublicKey is a public key of the registry that is used to verify signatures.
+// The key is encoded with standard base64.
+type PublicKey struct {
+	KeyID     string
+	Algorithm string
+	PublicKey string
+}
+
+// KeyID returns the ID of an ed25519 public key, the hex encoded first 8 bytes of its SHA-256 checksum.
+func KeyID(pub ed25519.PublicKey) string {
+	h := sha256.Sum256(pub)
+	return hex.EncodeToString(h[:8])
+}
+
+func NewPublicKey(pub ed25519.PublicKey) *PublicKey {
+	return &PublicKey{
+		KeyID:     KeyID(pub),
+		Algorithm: SignatureAlgorithmEd25519,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+	}
+}
+
+// ParsePublicKey parses a base64 encoded ed25519 public key.
+func ParsePublicKey(s string) (*PublicKey, error) {
+	pub, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(pub))
+	}
+	return NewPublicKey(pub), nil
+}
+
+func (k *PublicKey) Ed25519() (ed25519.PublicKey, error) {
+	if k.Algorithm != SignatureAlgorithmEd25519 {
+		return nil, fmt.Errorf("unsupported signature algorithm %s", k.Algorithm)
+	}
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key %s: %w", k.KeyID, err)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid size of public key %s: %d", k.KeyID, len(pub))
+	}
+	if KeyID(pub) != k.KeyID {
+		return nil, fmt.Errorf("public key does not match key ID %s", k.KeyID)
+	}
+	return pub, nil
+}
+
+// Signature is a detached ed25519 signature. The signature is encoded with standard base64.
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Signature string
+}
+
+// VerifySignature verifies the signature of the payload with one of the trusted keys.
+func VerifySignature(trustedKeys []*PublicKey, payload []byte, sig *Signature) error {
+	if sig == nil || sig.Signature == "" {
+		return ErrMissingSignature
+	}
+	if sig.Algorithm != SignatureAlgorithmEd25519 {
+		return fmt.Errorf("unsupported signature algorithm %s", sig.Algorithm)
+	}
+	var key *PublicKey
+	for _, k := range trustedKeys {
+		if k.KeyID == sig.KeyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("%w: %s", ErrUntrustedKey, sig.KeyID)
+	}
+	pub, err := key.Ed25519()
+	if err != nil {
+		return err
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if !ed25519.Verify(pub, payload, rawSig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SigningPayload returns the payload that is signed by the registry. The download hash covers the
+// platform, the archive format and all resolved plugins, the download checksum covers the archive content.
+func (b *BatchResponse) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("go-semantic-release-batch-response/v1\n%s\n%s\n", b.DownloadHash, b.DownloadChecksum))
+}
+
+// VerifySignature verifies the download hash and the signature of the batch response.
+func (b *BatchResponse) VerifySignature(trustedKeys []*PublicKey) error {
+	if !b.VerifyHash() {
+		return fmt.Errorf("download hash mismatch")
+	}
+	if b.DownloadChecksum == "" {
+		return fmt.Errorf("missing download checksum")
+	}
+	return VerifySignature(trustedKeys, b.SigningPayload(), b.Signature)
+}
+
+// ArchiveSigningPayload returns the payload of the detached signature of a batch archive with the given SHA-256 checksum.
+func ArchiveSigningPayload(checksum string) []byte {
+	return []byte(fmt.Sprintf("go-semantic-release-archive/v1\n%s\n", checksum))
+}